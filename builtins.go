@@ -4,11 +4,15 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"math/bits"
 	"math/rand"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"golang.org/x/exp/slices"
 )
@@ -52,21 +56,189 @@ func loadMath(g *lookup) {
 	g.Set("math.Sqrt", NewFunc(1, 1, func(v *VM) { a := get1f(v); set1f(v, math.Sqrt(a)) }))
 	g.Set("math.Tan", NewFunc(1, 1, func(v *VM) { a := get1f(v); set1f(v, math.Tan(a)) }))
 
+	g.Set("math.Acos", NewFunc(1, 1, func(v *VM) { a := get1f(v); set1f(v, math.Acos(a)) }))
+	g.Set("math.Acosh", NewFunc(1, 1, func(v *VM) { a := get1f(v); set1f(v, math.Acosh(a)) }))
+	g.Set("math.Asin", NewFunc(1, 1, func(v *VM) { a := get1f(v); set1f(v, math.Asin(a)) }))
+	g.Set("math.Asinh", NewFunc(1, 1, func(v *VM) { a := get1f(v); set1f(v, math.Asinh(a)) }))
+	g.Set("math.Atanh", NewFunc(1, 1, func(v *VM) { a := get1f(v); set1f(v, math.Atanh(a)) }))
+	g.Set("math.Cbrt", NewFunc(1, 1, func(v *VM) { a := get1f(v); set1f(v, math.Cbrt(a)) }))
+	g.Set("math.Copysign", NewFunc(2, 1, func(v *VM) { a, b := get2Pop1f(v); set1f(v, math.Copysign(a, b)) }))
+	g.Set("math.Cosh", NewFunc(1, 1, func(v *VM) { a := get1f(v); set1f(v, math.Cosh(a)) }))
+	g.Set("math.Dim", NewFunc(2, 1, func(v *VM) { a, b := get2Pop1f(v); set1f(v, math.Dim(a, b)) }))
+	g.Set("math.Exp", NewFunc(1, 1, func(v *VM) { a := get1f(v); set1f(v, math.Exp(a)) }))
+	g.Set("math.Exp2", NewFunc(1, 1, func(v *VM) { a := get1f(v); set1f(v, math.Exp2(a)) }))
+	g.Set("math.Expm1", NewFunc(1, 1, func(v *VM) { a := get1f(v); set1f(v, math.Expm1(a)) }))
+	g.Set("math.Inf", NewFunc(1, 1, func(v *VM) { a := get1f(v); set1f(v, math.Inf(int(a))) }))
+	g.Set("math.IsInf", NewFunc(2, 1, func(v *VM) { a, b := get2Pop1f(v); v.stack[len(v.stack)-1] = Bool(math.IsInf(a, int(b))) }))
+	g.Set("math.IsNaN", NewFunc(1, 1, func(v *VM) { a := get1f(v); v.stack[len(v.stack)-1] = Bool(math.IsNaN(a)) }))
+	g.Set("math.Log10", NewFunc(1, 1, func(v *VM) { a := get1f(v); set1f(v, math.Log10(a)) }))
+	g.Set("math.Log1p", NewFunc(1, 1, func(v *VM) { a := get1f(v); set1f(v, math.Log1p(a)) }))
+	g.Set("math.Log2", NewFunc(1, 1, func(v *VM) { a := get1f(v); set1f(v, math.Log2(a)) }))
+	g.Set("math.NaN", NewFunc(0, 1, func(v *VM) { push1f(v, math.NaN()) }))
+	g.Set("math.Remainder", NewFunc(2, 1, func(v *VM) { a, b := get2Pop1f(v); set1f(v, math.Remainder(a, b)) }))
+	g.Set("math.RoundToEven", NewFunc(1, 1, func(v *VM) { a := get1f(v); set1f(v, math.RoundToEven(a)) }))
+	g.Set("math.Sinh", NewFunc(1, 1, func(v *VM) { a := get1f(v); set1f(v, math.Sinh(a)) }))
+	g.Set("math.Tanh", NewFunc(1, 1, func(v *VM) { a := get1f(v); set1f(v, math.Tanh(a)) }))
+	g.Set("math.Trunc", NewFunc(1, 1, func(v *VM) { a := get1f(v); set1f(v, math.Trunc(a)) }))
+
+	g.Set("math.E", Float64(math.E))
 	g.Set("math.Pi", Float64(math.Pi))
+	g.Set("math.Phi", Float64(math.Phi))
+	g.Set("math.Sqrt2", Float64(math.Sqrt2))
+	g.Set("math.SqrtE", Float64(math.SqrtE))
+	g.Set("math.SqrtPi", Float64(math.SqrtPi))
+	g.Set("math.SqrtPhi", Float64(math.SqrtPhi))
+	g.Set("math.Ln2", Float64(math.Ln2))
+	g.Set("math.Log2E", Float64(math.Log2E))
+	g.Set("math.Ln10", Float64(math.Ln10))
+	g.Set("math.Log10E", Float64(math.Log10E))
+	g.Set("math.MaxFloat64", Float64(math.MaxFloat64))
+	g.Set("math.SmallestNonzeroFloat64", Float64(math.SmallestNonzeroFloat64))
+	g.Set("math.MaxFloat32", Float64(math.MaxFloat32))
+	g.Set("math.SmallestNonzeroFloat32", Float64(math.SmallestNonzeroFloat32))
+
+	// NOTE: int and uint are 32 bits in goatlang
+	g.Set("math.MaxInt", newUntypedInt(math.MaxInt32))
+	g.Set("math.MinInt", newUntypedInt(math.MinInt32))
+	g.Set("math.MaxUint", Value{t: untypedInt, num: math.MaxUint32})
+	g.Set("math.MaxInt8", newUntypedInt(math.MaxInt8))
+	g.Set("math.MinInt8", newUntypedInt(math.MinInt8))
+	g.Set("math.MaxInt16", newUntypedInt(math.MaxInt16))
+	g.Set("math.MinInt16", newUntypedInt(math.MinInt16))
+	g.Set("math.MaxInt32", newUntypedInt(math.MaxInt32))
+	g.Set("math.MinInt32", newUntypedInt(math.MinInt32))
+	g.Set("math.MaxUint8", newUntypedInt(math.MaxUint8))
+	g.Set("math.MaxUint16", newUntypedInt(math.MaxUint16))
+	g.Set("math.MaxUint32", Value{t: untypedInt, num: math.MaxUint32})
+}
+
+func loadMathBits(g *lookup) {
+	// NOTE: uint is 32 bits in goatlang
+	g.Set("math/bits.UintSize", newUntypedInt(32))
+
+	g.Set("math/bits.LeadingZeros", NewFunc(1, 1, func(v *VM, args []Value) Value { return Int(bits.LeadingZeros32(args[0].Uint32())) }))
+	g.Set("math/bits.LeadingZeros8", NewFunc(1, 1, func(v *VM, args []Value) Value { return Int(bits.LeadingZeros8(args[0].Uint8())) }))
+	g.Set("math/bits.LeadingZeros32", NewFunc(1, 1, func(v *VM, args []Value) Value { return Int(bits.LeadingZeros32(args[0].Uint32())) }))
+	g.Set("math/bits.TrailingZeros", NewFunc(1, 1, func(v *VM, args []Value) Value { return Int(bits.TrailingZeros32(args[0].Uint32())) }))
+	g.Set("math/bits.TrailingZeros8", NewFunc(1, 1, func(v *VM, args []Value) Value { return Int(bits.TrailingZeros8(args[0].Uint8())) }))
+	g.Set("math/bits.TrailingZeros32", NewFunc(1, 1, func(v *VM, args []Value) Value { return Int(bits.TrailingZeros32(args[0].Uint32())) }))
+	g.Set("math/bits.OnesCount", NewFunc(1, 1, func(v *VM, args []Value) Value { return Int(bits.OnesCount32(args[0].Uint32())) }))
+	g.Set("math/bits.OnesCount8", NewFunc(1, 1, func(v *VM, args []Value) Value { return Int(bits.OnesCount8(args[0].Uint8())) }))
+	g.Set("math/bits.OnesCount32", NewFunc(1, 1, func(v *VM, args []Value) Value { return Int(bits.OnesCount32(args[0].Uint32())) }))
+	g.Set("math/bits.Len", NewFunc(1, 1, func(v *VM, args []Value) Value { return Int(bits.Len32(args[0].Uint32())) }))
+	g.Set("math/bits.Len8", NewFunc(1, 1, func(v *VM, args []Value) Value { return Int(bits.Len8(args[0].Uint8())) }))
+	g.Set("math/bits.Len32", NewFunc(1, 1, func(v *VM, args []Value) Value { return Int(bits.Len32(args[0].Uint32())) }))
+
+	g.Set("math/bits.RotateLeft", NewFunc(2, 1, func(v *VM, args []Value) Value { return Uint32(bits.RotateLeft32(args[0].Uint32(), args[1].Int())) }))
+	g.Set("math/bits.RotateLeft8", NewFunc(2, 1, func(v *VM, args []Value) Value { return Uint8(bits.RotateLeft8(args[0].Uint8(), args[1].Int())) }))
+	g.Set("math/bits.RotateLeft32", NewFunc(2, 1, func(v *VM, args []Value) Value { return Uint32(bits.RotateLeft32(args[0].Uint32(), args[1].Int())) }))
+	g.Set("math/bits.Reverse", NewFunc(1, 1, func(v *VM, args []Value) Value { return Uint32(bits.Reverse32(args[0].Uint32())) }))
+	g.Set("math/bits.Reverse8", NewFunc(1, 1, func(v *VM, args []Value) Value { return Uint8(bits.Reverse8(args[0].Uint8())) }))
+	g.Set("math/bits.Reverse32", NewFunc(1, 1, func(v *VM, args []Value) Value { return Uint32(bits.Reverse32(args[0].Uint32())) }))
+	g.Set("math/bits.ReverseBytes", NewFunc(1, 1, func(v *VM, args []Value) Value { return Uint32(bits.ReverseBytes32(args[0].Uint32())) }))
+	g.Set("math/bits.ReverseBytes32", NewFunc(1, 1, func(v *VM, args []Value) Value { return Uint32(bits.ReverseBytes32(args[0].Uint32())) }))
+}
+
+func loadMathRand(g *VM) {
+	for _, k := range []string{"Float64", "Int", "Intn", "Int31", "Int31n", "Uint32", "Perm", "Shuffle", "Seed"} {
+		g.Set("math/rand."+k, randAttr(g.rand, k))
+	}
+	g.Set("math/rand.Rand", newType(TypeObject))
+	g.Set("math/rand.Source", newType(TypeObject))
+	g.Set("math/rand.NewSource", NewFunc(1, 1, func(v *VM, args []Value) Value {
+		return Wrap(&randSource{v: rand.NewSource(randSeed("rand.NewSource", args[0].Float64()))})
+	}))
+	g.Set("math/rand.New", NewFunc(1, 1, func(v *VM, args []Value) Value {
+		src, ok := args[0].value.(*randSource)
+		if !ok {
+			panicf("rand.New: invalid source: %v", args[0])
+		}
+		return Wrap(&randRand{v: rand.New(src.v)})
+	}))
+}
+
+type randSource struct {
+	Object
+	v rand.Source
+}
+
+type randRand struct {
+	Object
+	v     *rand.Rand
+	attrs map[string]Value
 }
 
-func loadMathRand(g *lookup) {
-	g.Set("math/rand.Float64", NewFunc(0, 1, func(v *VM) { push1f(v, rand.Float64()) }))
-	g.Set("math/rand.Int", NewFunc(0, 1, func(v *VM) { v.stack = append(v.stack, Int(rand.Int())) }))
-	g.Set("math/rand.Intn", NewFunc(1, 1, func(v *VM) { a := int(get1f(v)); v.stack[len(v.stack)-1] = Int(rand.Intn(a)) }))
-	g.Set("math/rand.Int31", NewFunc(0, 1, func(v *VM) { v.stack = append(v.stack, Int32(rand.Int31())) }))
-	g.Set("math/rand.Int31n", NewFunc(1, 1, func(v *VM) { a := int(get1f(v)); v.stack[len(v.stack)-1] = Int32(rand.Int31n(int32(a))) }))
+// GetAttr builds each method value once, so r.Intn in a loop does not allocate.
+func (r *randRand) GetAttr(k string) Value {
+	if a, ok := r.attrs[k]; ok {
+		return a
+	}
+	if r.attrs == nil {
+		r.attrs = map[string]Value{}
+	}
+	r.attrs[k] = randAttr(r.v, k)
+	return r.attrs[k]
+}
 
-	g.Set("math/rand.Uint32", NewFunc(0, 1, func(v *VM) { v.stack = append(v.stack, Uint32(rand.Uint32())) }))
-	g.Set("math/rand.Seed", NewFunc(1, 0, func(v *VM) { a := int64(pop1f(v)); rand.Seed(a) }))
+// randSeed converts a seed without rounding or saturating it. Integers are
+// float64 in the VM, so seeds are exact up to 2^53.
+func randSeed(name string, f float64) int64 {
+	if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		panicf("%v: seed %v is not an int64", name, f)
+	}
+	return int64(f)
+}
+
+func randAttr(r *rand.Rand, k string) (res Value) {
+	switch k {
+	case "Float64":
+		res = NewFunc(0, 1, func(v *VM) { push1f(v, r.Float64()) })
+	case "Int":
+		res = NewFunc(0, 1, func(v *VM) { v.stack = append(v.stack, Int(r.Int())) })
+	case "Intn":
+		res = NewFunc(1, 1, func(v *VM) { a := int(get1f(v)); v.stack[len(v.stack)-1] = Int(r.Intn(a)) })
+	case "Int31":
+		res = NewFunc(0, 1, func(v *VM) { v.stack = append(v.stack, Int32(r.Int31())) })
+	case "Int31n":
+		res = NewFunc(1, 1, func(v *VM) { a := int(get1f(v)); v.stack[len(v.stack)-1] = Int32(r.Int31n(int32(a))) })
+	case "Uint32":
+		res = NewFunc(0, 1, func(v *VM) { v.stack = append(v.stack, Uint32(r.Uint32())) })
+	case "Perm":
+		res = NewFunc(1, 1, func(v *VM, args []Value) Value {
+			perm := r.Perm(args[0].Int())
+			data := make([]Value, len(perm))
+			for i, n := range perm {
+				data[i] = Int(n)
+			}
+			return NewSlice(TypeInt32, data)
+		})
+	case "Shuffle":
+		res = NewFunc(2, 0, func(v *VM, args []Value) {
+			r.Shuffle(args[0].Int(), func(i, j int) {
+				if _, err := v.Func(args[1], 0, Int(i), Int(j)); err != nil {
+					panic(err)
+				}
+			})
+		})
+	case "Seed":
+		res = NewFunc(1, 0, func(v *VM) { a := randSeed("rand.Seed", pop1f(v)); r.Seed(a) })
+	}
+	return res
 }
 
-func sprint(v *VM, a Value) string { return a.String() }
+// sprint formats a like fmt does, with the String method of a named type.
+func sprint(v *VM, a Value) string {
+	if n := a.t.named(); n > 0 && v.globals.Exists("String") {
+		if idx, ok := v.globals.method(n, v.globals.Index("String")); ok {
+			rets, err := v.Func(v.globals.Read(idx), 1, a)
+			if err != nil {
+				panic(err)
+			}
+			return rets[0].String()
+		}
+	}
+	return a.String()
+}
 func vaSprint(v *VM, va []Value) string {
 	res := make([]string, len(va))
 	for i, a := range va {
@@ -94,12 +266,145 @@ func loadFmt(g *lookup) {
 		}
 		return []Value{String(fmt.Sprintf(args[0].String(), va...))}
 	}))
+	g.Set("fmt.Errorf", NewFunc(2, 1, func(v *VM, args []Value, vargs ...Value) []Value {
+		var va []any
+		for _, a := range vargs {
+			if err, ok := v.asError(a); ok && a.value != nil {
+				va = append(va, err)
+			} else {
+				va = append(va, a)
+			}
+		}
+		return []Value{Error(fmt.Errorf(args[0].String(), va...))}
+	}))
 }
 
 func loadErrors(g *lookup) {
 	g.Set("errors.New", NewFunc(1, 1, func(v *VM, args []Value) Value {
 		return Wrap(&errorT{err: errors.New(args[0].String())})
 	}))
+	g.Set("errors.Is", NewFunc(2, 1, func(v *VM, args []Value) Value {
+		return Bool(errors.Is(v.goError(args[0]), v.goError(args[1])))
+	}))
+	g.Set("errors.Unwrap", NewFunc(1, 1, func(v *VM, args []Value) Value {
+		return v.errorValue(errors.Unwrap(v.goError(args[0])))
+	}))
+	g.Set("errors.Join", NewFunc(1, 1, func(v *VM, args []Value, vargs ...Value) []Value {
+		errs := make([]error, len(vargs))
+		for i, a := range vargs {
+			errs[i] = v.goError(a)
+		}
+		return []Value{v.errorValue(errors.Join(errs...))}
+	}))
+	// errors.As(err, &target) is compiled to target's value in, (ok, match) out.
+	g.Set("errors.As", NewFunc(2, 2, func(v *VM, args []Value) []Value {
+		if match, ok := v.errorsAs(v.goError(args[0]), args[1]); ok {
+			return []Value{Bool(true), match}
+		}
+		return []Value{Bool(false), args[1]}
+	}))
+}
+
+// goError returns v as a Go error, nil when v is nil.
+func (vm *VM) goError(v Value) error {
+	if e, ok := vm.asError(v); ok {
+		return e
+	}
+	panicf("%v does not implement error (missing method Error)", v.t.str(vm.globals))
+	return nil
+}
+
+// AsError is Value.AsError for values of vm, including named types like
+// `type E string` with an Error method.
+func (vm *VM) AsError(v Value) error {
+	err, _ := vm.asError(v)
+	return err
+}
+
+// asError returns v as a Go error when it is nil, a Go error or has an
+// Error method. Script errors call back into the root VM, as vm may be a
+// transient one from run.
+func (vm *VM) asError(v Value) (error, bool) {
+	switch o := v.value.(type) {
+	case nil:
+		return nil, v.t == TypeNil || v.t.base() >= nillableMin
+	case *errorT:
+		return o.err, true
+	}
+	if _, ok := v.value.(*structT); (ok || v.t.named() > 0) && vm.scriptMethod(v, "Error") {
+		if vm.root != nil {
+			vm = vm.root
+		}
+		return scriptError{vm: vm, v: v}, true
+	}
+	return nil, false
+}
+
+// errorValue returns err as a script value, unwrapping script errors.
+func (vm *VM) errorValue(err error) Value {
+	switch e := err.(type) {
+	case nil:
+		return Nil()
+	case scriptError:
+		return e.v
+	}
+	return Error(err)
+}
+
+// errorsAs finds the first error in the tree of err with the type of
+// target.
+func (vm *VM) errorsAs(err error, target Value) (Value, bool) {
+	for err != nil {
+		if e, ok := err.(scriptError); ok && e.v.t == target.t {
+			return e.v, true
+		}
+		switch u := err.(type) {
+		case interface{ Unwrap() error }:
+			err = u.Unwrap()
+		case interface{ Unwrap() []error }:
+			for _, err := range u.Unwrap() {
+				if v, ok := vm.errorsAs(err, target); ok {
+					return v, true
+				}
+			}
+			return Nil(), false
+		default:
+			return Nil(), false
+		}
+	}
+	return Nil(), false
+}
+
+func (vm *VM) scriptMethod(v Value, name string) bool {
+	return vm.globals.Exists(name) && v.hasMethod(vm, vm.globals.Index(name))
+}
+
+// scriptError is a script value with an Error method used as a Go error.
+type scriptError struct {
+	vm *VM
+	v  Value
+}
+
+func (e scriptError) call(name string) Value {
+	rets, err := e.vm.Func(e.v.getIndex(e.vm, e.vm.globals.Index(name)), 1)
+	if err != nil {
+		panic(err)
+	}
+	return rets[0]
+}
+
+func (e scriptError) Error() string { return e.call("Error").String() }
+
+func (e scriptError) Unwrap() error {
+	if !e.vm.scriptMethod(e.v, "Unwrap") {
+		return nil
+	}
+	return e.vm.goError(e.call("Unwrap"))
+}
+
+func (e scriptError) Is(target error) bool {
+	t, ok := target.(scriptError)
+	return ok && e.v.t == t.v.t && e.v.Equals(t.v)
 }
 
 type errorT struct {
@@ -124,6 +429,22 @@ func Error(err error) Value {
 	return Wrap(&errorT{err: err})
 }
 
+// AsError returns v as a Go error, nil when v is nil or not an error. For a
+// script struct the error calls its Error method in the VM that defined it.
+// Other named types only know their methods through the VM, see VM.AsError.
+func (v Value) AsError() error {
+	switch o := v.value.(type) {
+	case *errorT:
+		return o.err
+	case *structT:
+		if f := o.method("Error"); f != nil && f.vm != nil {
+			err, _ := f.vm.asError(v)
+			return err
+		}
+	}
+	return nil
+}
+
 func loadStrings(g *lookup) {
 	g.Set("strings.Split", NewFunc(2, 1, func(v *VM) {
 		s, sep := get2Pop1v(v)
@@ -171,6 +492,37 @@ func loadStrings(g *lookup) {
 	}))
 }
 
+func loadUnicode(g *lookup) {
+	g.Set("unicode.MaxRune", Int32(unicode.MaxRune))
+	g.Set("unicode.IsLetter", NewFunc(1, 1, func(v *VM, args []Value) Value { return Bool(unicode.IsLetter(args[0].Int32())) }))
+	g.Set("unicode.IsDigit", NewFunc(1, 1, func(v *VM, args []Value) Value { return Bool(unicode.IsDigit(args[0].Int32())) }))
+	g.Set("unicode.IsNumber", NewFunc(1, 1, func(v *VM, args []Value) Value { return Bool(unicode.IsNumber(args[0].Int32())) }))
+	g.Set("unicode.IsSpace", NewFunc(1, 1, func(v *VM, args []Value) Value { return Bool(unicode.IsSpace(args[0].Int32())) }))
+	g.Set("unicode.IsPunct", NewFunc(1, 1, func(v *VM, args []Value) Value { return Bool(unicode.IsPunct(args[0].Int32())) }))
+	g.Set("unicode.IsUpper", NewFunc(1, 1, func(v *VM, args []Value) Value { return Bool(unicode.IsUpper(args[0].Int32())) }))
+	g.Set("unicode.IsLower", NewFunc(1, 1, func(v *VM, args []Value) Value { return Bool(unicode.IsLower(args[0].Int32())) }))
+	g.Set("unicode.ToUpper", NewFunc(1, 1, func(v *VM, args []Value) Value { return Int32(unicode.ToUpper(args[0].Int32())) }))
+	g.Set("unicode.ToLower", NewFunc(1, 1, func(v *VM, args []Value) Value { return Int32(unicode.ToLower(args[0].Int32())) }))
+
+	g.Set("unicode/utf8.RuneError", Int32(utf8.RuneError))
+	g.Set("unicode/utf8.UTFMax", newUntypedInt(utf8.UTFMax))
+	g.Set("unicode/utf8.RuneLen", NewFunc(1, 1, func(v *VM, args []Value) Value { return Int(utf8.RuneLen(args[0].Int32())) }))
+	g.Set("unicode/utf8.RuneCountInString", NewFunc(1, 1, func(v *VM, args []Value) Value { return Int(utf8.RuneCountInString(args[0].String())) }))
+	g.Set("unicode/utf8.ValidString", NewFunc(1, 1, func(v *VM, args []Value) Value { return Bool(utf8.ValidString(args[0].String())) }))
+	g.Set("unicode/utf8.ValidRune", NewFunc(1, 1, func(v *VM, args []Value) Value { return Bool(utf8.ValidRune(args[0].Int32())) }))
+	g.Set("unicode/utf8.DecodeRuneInString", NewFunc(1, 2, func(v *VM, args []Value) []Value {
+		r, size := utf8.DecodeRuneInString(args[0].String())
+		return []Value{Int32(r), Int(size)}
+	}))
+	g.Set("unicode/utf8.DecodeLastRuneInString", NewFunc(1, 2, func(v *VM, args []Value) []Value {
+		r, size := utf8.DecodeLastRuneInString(args[0].String())
+		return []Value{Int32(r), Int(size)}
+	}))
+	g.Set("unicode/utf8.AppendRune", NewFunc(2, 1, func(v *VM, args []Value) Value {
+		return Bytes(utf8.AppendRune(args[0].Bytes(), args[1].Int32()))
+	}))
+}
+
 const builtinYield = "builtin.__yield"
 
 func loadBuiltin(g *lookup) {
@@ -184,13 +536,145 @@ func loadBuiltin(g *lookup) {
 		return nil
 	}))
 	g.Set(builtinYield, NewFunc(0, 0, func(v *VM) {}))
+	g.Set("builtin.__int64", newType(namedType(g.Index("builtin.__int64"), TypeFloat64)))
+	setMethod(g, "builtin.__int64", "String", NewFunc(1, 1, func(v *VM, args []Value) Value {
+		return String(strconv.FormatFloat(args[0].Float64(), 'f', -1, 64))
+	}))
+}
+
+// setMethod adds the host method name to the named type key, callable on
+// values and as the method expression key.name.
+func setMethod(g *lookup, key, name string, f Value) {
+	g.Set(key+"."+name, f)
+	g.setMethod(g.Index(key), g.Index(name), g.Index(key+"."+name), false)
 }
 
-func loadTime(g *lookup) {
+const builtinTimers = "time.__timers"
+
+func loadTime(g *VM) {
+	clock := g.clock
 	// NOTE: int32 isn't adequate, using float64 where we can
-	g.Set("time.Sleep", NewFunc(1, 0, func(v *VM) { v.Yield(); a := pop1f(v); time.Sleep(time.Duration(a)) }))
-	g.Set("time.Now", NewFunc(0, 1, func(v *VM) { v.stack = append(v.stack, Wrap(newTime(time.Now()))) }))
-	g.Set("time.Second", Float64(float64(time.Second)))
+	g.Set("time.Sleep", NewFunc(1, 0, func(v *VM) { a := pop1f(v); clock.Sleep(time.Duration(a)); v.Yield() }))
+	g.Set("time.Now", NewFunc(0, 1, func(v *VM) { v.stack = append(v.stack, Wrap(newTime(clock.Now()))) }))
+	g.Set("time.Since", NewFunc(1, 1, func(v *VM, args []Value) Value {
+		return duration(v, clock.Now().Sub(toTime("time.Since", args[0])))
+	}))
+	g.Set("time.Unix", NewFunc(2, 1, func(v *VM, args []Value) Value {
+		return Wrap(newTime(time.Unix(int64(args[0].Float64()), int64(args[1].Float64()))))
+	}))
+	g.Set("time.UnixMilli", NewFunc(1, 1, func(v *VM, args []Value) Value {
+		return Wrap(newTime(time.UnixMilli(int64(args[0].Float64()))))
+	}))
+	g.Set("time.Parse", NewFunc(2, 2, func(v *VM, args []Value) []Value {
+		t, err := time.Parse(args[0].String(), args[1].String())
+		if err != nil {
+			return []Value{Wrap(newTime(t)), Error(err)}
+		}
+		return []Value{Wrap(newTime(t)), Nil()}
+	}))
+
+	g.Set("time.Time", newType(TypeObject))
+	g.Set("time.Timer", newType(TypeObject))
+	g.Set("time.Duration", newType(namedType(g.globals.Index("time.Duration"), TypeFloat64)))
+	g.Set("time.Nanosecond", duration(g, time.Nanosecond))
+	g.Set("time.Microsecond", duration(g, time.Microsecond))
+	g.Set("time.Millisecond", duration(g, time.Millisecond))
+	g.Set("time.Second", duration(g, time.Second))
+	g.Set("time.Minute", duration(g, time.Minute))
+	g.Set("time.Hour", duration(g, time.Hour))
+
+	setMethod(g.globals, "time.Duration", "Hours", NewFunc(1, 1, func(v *VM) { a := get1f(v); set1f(v, time.Duration(a).Hours()) }))
+	setMethod(g.globals, "time.Duration", "Minutes", NewFunc(1, 1, func(v *VM) { a := get1f(v); set1f(v, time.Duration(a).Minutes()) }))
+	setMethod(g.globals, "time.Duration", "Seconds", NewFunc(1, 1, func(v *VM) { a := get1f(v); set1f(v, time.Duration(a).Seconds()) }))
+	setMethod(g.globals, "time.Duration", "Milliseconds", NewFunc(1, 1, func(v *VM, args []Value) Value {
+		return int64Value(v, float64(time.Duration(args[0].Float64()).Milliseconds()))
+	}))
+	setMethod(g.globals, "time.Duration", "String", NewFunc(1, 1, func(v *VM, args []Value) Value {
+		return String(time.Duration(args[0].Float64()).String())
+	}))
+
+	g.Set("time.Layout", String(time.Layout))
+	g.Set("time.RFC3339", String(time.RFC3339))
+	g.Set("time.Kitchen", String(time.Kitchen))
+	g.Set("time.DateTime", String(time.DateTime))
+	g.Set("time.DateOnly", String(time.DateOnly))
+	g.Set("time.TimeOnly", String(time.TimeOnly))
+
+	// NOTE: timers are cooperative, callbacks run from VM.Yield once due
+	var timers []*timeTimer
+	g.Set("time.AfterFunc", NewFunc(2, 1, func(v *VM, args []Value) Value {
+		t := &timeTimer{f: args[1], clock: clock, timers: &timers}
+		t.reset(time.Duration(args[0].Float64()))
+		return Wrap(t)
+	}))
+	g.Set(builtinTimers, NewFunc(0, 0, func(v *VM) {
+		now := clock.Now()
+		var due, next []*timeTimer
+		for _, t := range timers {
+			switch {
+			case !t.active:
+				t.queued = false
+			case now.Before(t.when):
+				next = append(next, t)
+			default:
+				t.active, t.queued = false, false
+				due = append(due, t)
+			}
+		}
+		timers = next
+		sort.SliceStable(due, func(i, j int) bool { return due[i].when.Before(due[j].when) })
+		for _, t := range due {
+			if _, err := v.Func(t.f, 0); err != nil {
+				panic(err)
+			}
+		}
+	}))
+}
+
+// duration returns d as a time.Duration.
+func duration(vm *VM, d time.Duration) Value {
+	return Value{t: vm.globals.Get("time.Duration").typeValue(), num: float64(d)}
+}
+
+// int64Value returns f as a builtin.__int64, a float64 printed as an integer
+// for Go APIs returning int64 values that do not fit an int.
+func int64Value(vm *VM, f float64) Value {
+	return Value{t: vm.globals.Get("builtin.__int64").typeValue(), num: f}
+}
+
+type timeTimer struct {
+	Object
+	f              Value
+	when           time.Time
+	active, queued bool
+	clock          Clock
+	timers         *[]*timeTimer
+}
+
+func (t *timeTimer) reset(d time.Duration) bool {
+	was := t.active
+	t.when, t.active = t.clock.Now().Add(d), true
+	if !t.queued {
+		*t.timers = append(*t.timers, t)
+		t.queued = true
+	}
+	return was
+}
+
+func (t *timeTimer) GetAttr(k string) (res Value) {
+	switch k {
+	case "Stop":
+		res = NewFunc(0, 1, func(vm *VM) Value {
+			was := t.active
+			t.active = false
+			return Bool(was)
+		})
+	case "Reset":
+		res = NewFunc(1, 1, func(vm *VM, args []Value) Value {
+			return Bool(t.reset(time.Duration(args[0].Float64())))
+		})
+	}
+	return res
 }
 
 type timeTime struct {
@@ -202,13 +686,51 @@ func newTime(t time.Time) *timeTime {
 	return &timeTime{v: t}
 }
 
+func (t *timeTime) String() string { return t.v.String() }
+
+// toTime unwraps the time.Time argument of the func name.
+func toTime(name string, v Value) time.Time {
+	t, ok := v.value.(*timeTime)
+	if !ok {
+		panicf("%v: %v is not a time.Time", name, v)
+	}
+	return t.v
+}
+
 func (t *timeTime) GetAttr(k string) (res Value) {
 	switch k {
-	case "UnixMilli":
-		res = NewFunc(0, 1, func(vm *VM, args []Value) Value {
-			// wraps around every few days, due to truncation
-			return Int32(int32(t.v.UnixMilli()))
+	case "Add":
+		res = NewFunc(1, 1, func(vm *VM, args []Value) Value {
+			return Wrap(newTime(t.v.Add(time.Duration(args[0].Float64()))))
 		})
+	case "Sub":
+		res = NewFunc(1, 1, func(vm *VM, args []Value) Value {
+			return duration(vm, t.v.Sub(toTime("Time.Sub", args[0])))
+		})
+	case "Before":
+		res = NewFunc(1, 1, func(vm *VM, args []Value) Value {
+			return Bool(t.v.Before(toTime("Time.Before", args[0])))
+		})
+	case "After":
+		res = NewFunc(1, 1, func(vm *VM, args []Value) Value {
+			return Bool(t.v.After(toTime("Time.After", args[0])))
+		})
+	case "Equal":
+		res = NewFunc(1, 1, func(vm *VM, args []Value) Value {
+			return Bool(t.v.Equal(toTime("Time.Equal", args[0])))
+		})
+	case "Format":
+		res = NewFunc(1, 1, func(vm *VM, args []Value) Value {
+			return String(t.v.Format(args[0].String()))
+		})
+	case "UTC":
+		res = NewFunc(0, 1, func(vm *VM) Value { return Wrap(newTime(t.v.UTC())) })
+	case "Unix":
+		res = NewFunc(0, 1, func(vm *VM) Value { return int64Value(vm, float64(t.v.Unix())) })
+	case "UnixMilli":
+		res = NewFunc(0, 1, func(vm *VM) Value { return int64Value(vm, float64(t.v.UnixMilli())) })
+	case "String":
+		res = NewFunc(0, 1, func(vm *VM) Value { return String(t.v.String()) })
 	}
 	return res
 }
@@ -217,7 +739,7 @@ func loadMaps(g *lookup) {
 	g.Set("golang.org/x/exp/maps.Clone", NewFunc(1, 1, func(vm *VM, args []Value) Value {
 		src := args[0]
 		var in []Value
-		next := src.Range()
+		next := vm.mapRange(src)
 		for {
 			key, value, ok := next()
 			if !ok {
@@ -231,7 +753,7 @@ func loadMaps(g *lookup) {
 	g.Set("golang.org/x/exp/maps.Keys", NewFunc(1, 1, func(vm *VM, args []Value) Value {
 		src := args[0]
 		var in []Value
-		next := src.Range()
+		next := vm.mapRange(src)
 		for {
 			key, _, ok := next()
 			if !ok {
@@ -276,24 +798,19 @@ func loadOs(g *VM) {
 		args = append(args, String(v))
 	}
 	g.Set("os.Args", NewSlice(TypeString, args))
+	g.Set("os.ErrNotExist", Error(os.ErrNotExist))
+	g.Set("os.ErrExist", Error(os.ErrExist))
+	g.Set("os.ErrPermission", Error(os.ErrPermission))
 	g.Set("os.ReadFile", NewFunc(1, 2, func(vm *VM, args []Value) []Value {
 		b, err := osReadFile(args[0].String())
 		if err != nil {
 			return []Value{Nil(), Error(err)}
 		}
-		var res []Value
-		for _, v := range b {
-			res = append(res, Byte(v))
-		}
-		return []Value{NewSlice(TypeUint8, res), Nil()}
+		return []Value{Bytes(b), Nil()}
 	}))
 	g.Set("os.WriteFile", NewFunc(3, 1, func(vm *VM, args []Value) Value {
 		name := args[0].String()
-		var b []byte
-		for _, v := range args[1].data() {
-			b = append(b, v.Uint8())
-		}
-		err := osWriteFile(name, b, os.FileMode(args[2].Uint32()))
+		err := osWriteFile(name, args[1].Bytes(), os.FileMode(args[2].Uint32()))
 		if err != nil {
 			return Error(err)
 		}
@@ -316,34 +833,38 @@ func loadSlices(g *VM) {
 	}))
 	g.Set("golang.org/x/exp/slices.Delete", NewFunc(3, 1, func(vm *VM, args []Value) Value {
 		s := args[0].data()
-		vt := args[0].t.value()
-		return newSlice(vt, slices.Delete(s, args[1].Int(), args[2].Int()))
+		n := len(slices.Delete(s, args[1].Int(), args[2].Int()))
+		args[0].update(s)
+		return args[0].Slice(0, n)
 	}))
 	g.Set("golang.org/x/exp/slices.SortStableFunc", NewFunc(2, 0, func(vm *VM, args []Value) {
 		s := args[0].data()
 		slices.SortStableFunc(s, func(a, b Value) bool {
-			rets, err := g.Func(args[1], 1, a, b)
+			rets, err := vm.Func(args[1], 1, a, b)
 			if err != nil {
 				panic(err)
 			}
 			return rets[0].Bool()
 		})
+		args[0].update(s)
 	}))
 	g.Set("golang.org/x/exp/slices.SortFunc", NewFunc(2, 0, func(vm *VM, args []Value) {
 		s := args[0].data()
 		slices.SortFunc(s, func(a, b Value) bool {
-			rets, err := g.Func(args[1], 1, a, b)
+			rets, err := vm.Func(args[1], 1, a, b)
 			if err != nil {
 				panic(err)
 			}
 			return rets[0].Bool()
 		})
+		args[0].update(s)
 	}))
 	g.Set("golang.org/x/exp/slices.Sort", NewFunc(1, 0, func(vm *VM, args []Value) {
 		s := args[0].data()
 		slices.SortFunc(s, func(a, b Value) bool {
 			return a.opLt(b).Bool()
 		})
+		args[0].update(s)
 	}))
 	g.Set("golang.org/x/exp/slices.Equal", NewFunc(2, 1, func(vm *VM, args []Value) Value {
 		a := args[0].data()
@@ -3,9 +3,11 @@ package goatlang
 import (
 	"bytes"
 	"fmt"
+	"math/rand"
 	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestBuiltins(t *testing.T) {
@@ -32,6 +34,51 @@ func TestBuiltins(t *testing.T) {
 		{"math.Sqrt", `import "math"; v = math.Sqrt(1764); v`, `42`},
 		{"math.Tan", `import "math"; v = math.Tan(0); v`, `0`},
 		{"math.Pi", `import "math"; math.Pi`, `3.141592653589793`},
+		{"math.Acos", `import "math"; v = math.Acos(1); v`, `0`},
+		{"math.Acosh", `import "math"; v = math.Acosh(1); v`, `0`},
+		{"math.Asin", `import "math"; v = math.Asin(0); v`, `0`},
+		{"math.Asinh", `import "math"; v = math.Asinh(0); v`, `0`},
+		{"math.Atanh", `import "math"; v = math.Atanh(0); v`, `0`},
+		{"math.Cbrt", `import "math"; v = math.Cbrt(27); v`, `3`},
+		{"math.Copysign", `import "math"; v = math.Copysign(42,-1); v`, `-42`},
+		{"math.Cosh", `import "math"; v = math.Cosh(0); v`, `1`},
+		{"math.Dim", `import "math"; v = math.Dim(44,2); v`, `42`},
+		{"math.Exp", `import "math"; v = math.Exp(0); v`, `1`},
+		{"math.Exp2", `import "math"; v = math.Exp2(5); v`, `32`},
+		{"math.Expm1", `import "math"; v = math.Expm1(0); v`, `0`},
+		{"math.Inf", `import "math"; v = math.Inf(-1); v`, `-Inf`},
+		{"math.IsInf", `import "math"; v = math.IsInf(math.Inf(1),0); v`, `true`},
+		{"math.IsNaN", `import "math"; v = math.IsNaN(math.NaN()); v`, `true`},
+		{"math.Log10", `import "math"; v = math.Log10(100); v`, `2`},
+		{"math.Log1p", `import "math"; v = math.Log1p(0); v`, `0`},
+		{"math.Log2", `import "math"; v = math.Log2(64); v`, `6`},
+		{"math.NaN", `import "math"; v = math.NaN(); v`, `NaN`},
+		{"math.Remainder", `import "math"; v = math.Remainder(85,43); v`, `-1`},
+		{"math.RoundToEven", `import "math"; v = math.RoundToEven(42.5); v`, `42`},
+		{"math.Sinh", `import "math"; v = math.Sinh(0); v`, `0`},
+		{"math.Tanh", `import "math"; v = math.Tanh(0); v`, `0`},
+		{"math.Trunc", `import "math"; v = math.Trunc(-42.9); v`, `-42`},
+		{"math.E", `import "math"; math.E`, `2.718281828459045`},
+		{"math.Sqrt2", `import "math"; math.Sqrt2`, `1.4142135623730951`},
+		{"math.MaxFloat64", `import "math"; math.MaxFloat64`, `1.7976931348623157e+308`},
+		{"math.SmallestNonzeroFloat64", `import "math"; math.SmallestNonzeroFloat64`, `5e-324`},
+		{"math.MaxInt32", `import "math"; v := math.MaxInt32; t := __type(v); v; t`, `2147483647 int32`},
+		{"math.MinInt32", `import "math"; v := math.MinInt32; v`, `-2147483648`},
+		{"math.MaxUint32", `import "math"; var v uint32 = math.MaxUint32; v`, `4294967295`},
+		{"math.MaxUint8", `import "math"; var v byte = math.MaxUint8; v`, `255`},
+
+		{"bits.UintSize", `import "math/bits"; bits.UintSize`, `32`},
+		{"bits.LeadingZeros", `import "math/bits"; v := bits.LeadingZeros(uint(1)); v`, `31`},
+		{"bits.LeadingZeros8", `import "math/bits"; v := bits.LeadingZeros8(byte(1)); v`, `7`},
+		{"bits.TrailingZeros32", `import "math/bits"; v := bits.TrailingZeros32(uint32(8)); v`, `3`},
+		{"bits.OnesCount", `import "math/bits"; v := bits.OnesCount(uint(255)); t := __type(v); v; t`, `8 int32`},
+		{"bits.OnesCount8", `import "math/bits"; v := bits.OnesCount8(byte(7)); v`, `3`},
+		{"bits.Len32", `import "math/bits"; v := bits.Len32(uint32(42)); v`, `6`},
+		{"bits.RotateLeft32", `import "math/bits"; v := bits.RotateLeft32(uint32(1), -1); t := __type(v); v; t`, `2147483648 uint32`},
+		{"bits.RotateLeft8", `import "math/bits"; v := bits.RotateLeft8(byte(0x81), 1); t := __type(v); v; t`, `3 uint8`},
+		{"bits.Reverse", `import "math/bits"; v := bits.Reverse(uint(1)); v`, `2147483648`},
+		{"bits.Reverse8", `import "math/bits"; v := bits.Reverse8(byte(1)); t := __type(v); v; t`, `128 uint8`},
+		{"bits.ReverseBytes32", `import "math/bits"; v := bits.ReverseBytes32(uint32(0x01020304)); v`, `67305985`},
 
 		{"rand.Float64", `import "math/rand"; v = rand.Float64(); v < 1`, `true`},
 		{"rand.Uint32", `import "math/rand"; v = rand.Uint32(); v != 0`, `true`},
@@ -40,15 +87,31 @@ func TestBuiltins(t *testing.T) {
 		{"rand.Int31n", `import "math/rand"; v = rand.Int31n(42); v < 42`, `true`},
 		{"rand.Int31", `import "math/rand"; v = rand.Int31()%42; v < 42`, `true`},
 		{"rand.Seed", `import "math/rand"; rand.Seed(0); v = rand.Intn(42); v`, `12`},
+		{"rand.Perm", `import "math/rand"; rand.Seed(0); rand.Intn(42); v := rand.Perm(5); v`, `[0 4 1 3 2]`},
+		{"rand.New", `import "math/rand"; r := rand.New(rand.NewSource(0)); a := r.Intn(42); p := r.Perm(5); a; p`, `12 [0 4 1 3 2]`},
+		{"rand.New/Shuffle", `import "math/rand"; r := rand.New(rand.NewSource(0)); r.Intn(42); r.Perm(5); s := []int{1,2,3,4,5}; r.Shuffle(len(s), func(i, j int) { s[i], s[j] = s[j], s[i] }); s`, `[2 5 4 3 1]`},
+		{"rand.New/Float64", `import "math/rand"; r := rand.New(rand.NewSource(0)); v := r.Float64(); v < 1`, `true`},
+		{"rand.Rand", `import "math/rand"; var r *rand.Rand; r = rand.New(rand.NewSource(0)); v := r.Intn(42); v`, `12`},
+		{"rand.New/bigSeed", `import "math/rand"; r := rand.New(rand.NewSource(1<<62)); v := r.Intn(1000); v`, `81`},
+		{"rand.New/Seed", `import "math/rand"; r := rand.New(rand.NewSource(1)); r.Seed(0); v := r.Intn(42); v`, `12`},
 
 		{"fmt.Sprint", `import "fmt"; v = fmt.Sprint(42); v`, `42`},
 		{"fmt.Print", `import "fmt"; fmt.Print(42)`, `;42`},
 		{"fmt.Println", `import "fmt"; fmt.Println(42)`, ";42\n"},
 		{"print", `print(42)`, `;42`},
 		{"println", `println(42)`, ";42\n"},
+		{"println/String", `type Dir int; func (d Dir) String() string { return "dir" }; var d Dir = 1; println(d, 2)`, ";dir 2\n"},
 		{"fmt.Print/vargs", `import "fmt"; fmt.Print(40,2)`, `;40 2`},
 		{"fmt.Print/ellipsis", `import "fmt"; fmt.Print([]int{40,2}...)`, `;40 2`},
 		{"fmt.Sprintf", `import "fmt"; v = fmt.Sprintf("%v",42); v`, `42`},
+		{"fmt.Errorf", `import "errors"; import "fmt"; e := errors.New("x"); err := fmt.Errorf("y: %w", e); err, errors.Unwrap(err) == e`, `y: x true`},
+		{"errors.Is", `import "errors"; import "fmt"; e := errors.New("x"); err := fmt.Errorf("y: %w", e); errors.Is(err, e), errors.Is(err, errors.New("x"))`, `true false`},
+		{"errors.Is/struct", `import "errors"; type E struct { N int }; func (e E) Error() string { return "e" }; err := errors.Join(errors.New("x"), E{N: 1}); errors.Is(err, E{N: 1}), errors.Is(err, E{N: 2})`, `true false`},
+		{"errors.Unwrap", `import "errors"; type W struct { Err error }; func (w *W) Error() string { return "w: " + w.Err.Error() }; func (w *W) Unwrap() error { return w.Err }; e := errors.New("x"); w := &W{Err: e}; w.Error(), errors.Unwrap(w) == e, errors.Is(w, e), errors.Unwrap(e)`, `w: x true true nil`},
+		{"errors.Join", `import "errors"; err := errors.Join(errors.New("a"), nil, errors.New("b")); err, errors.Join(nil, nil)`, "a\nb nil"},
+		{"errors.As", `import "errors"; import "fmt"; type E struct { N int }; func (e *E) Error() string { return "e" }; err := fmt.Errorf("y: %w", &E{N: 42}); var e *E; ok := errors.As(err, &e); ok, e.N`, `true 42`},
+		{"errors.As/miss", `import "errors"; type E struct { N int }; func (e *E) Error() string { return "e" }; var e *E; ok := errors.As(errors.New("x"), &e); ok, e == nil`, `false true`},
+		{"errors.As/value", `import "errors"; type E struct { N int }; func (e E) Error() string { return "e" }; var e E; ok := errors.As(errors.Join(E{N: 42}), &e); ok, e.N`, `true 42`},
 
 		{"strings.Split", `import "strings"; v := strings.Split("a,b,c", ","); v`, `[a b c]`},
 		{"strings.Join", `import "strings"; v := strings.Join([]string{"a","b","c"},","); v`, `a,b,c`},
@@ -63,7 +126,26 @@ func TestBuiltins(t *testing.T) {
 		{"__type", `v = __type(42); v`, `number`},
 
 		{"time.Sleep", `import "time"; time.Sleep(0)`, ``},
-		{"time.Time.UnixMilli", `import "time"; v := time.Now().UnixMilli(); v!=0`, `true`},
+		{"time.Time.UnixMilli", `import "time"; v := time.Now().UnixMilli(); v > 1e12`, `true`},
+		{"time.Duration", `import "time"; import "fmt"; var d time.Duration = 90*time.Minute; e := time.Duration(2)*time.Hour; s := fmt.Sprint(d, e); s`, `1h30m0s 2h0m0s`},
+		{"time.Duration/print", `import "time"; println(1500*time.Millisecond)`, ";1.5s\n"},
+		{"time.Duration/methods", `import "time"; d := 1500*time.Millisecond; x, y, z := d.Seconds(), d.Milliseconds(), d.String(); x; y; z`, `1.5 1500 1.5s`},
+		{"time.Duration/type", `import "time"; d := time.Second; v := __type(d); v`, `time.Duration`},
+		{"time.Duration.Seconds", `import "time"; v := time.Duration.Seconds(1500*time.Millisecond); v`, `1.5`},
+		{"time.Duration.Hours", `import "time"; v := time.Duration.Hours(90*time.Minute); v`, `1.5`},
+		{"time.Duration.String", `import "time"; v := time.Duration.String(90*time.Minute); v`, `1h30m0s`},
+		{"time.Since", `import "time"; t := time.Now(); v := time.Since(t); v >= 0`, `true`},
+		{"time.Since/Milliseconds", `import "time"; t := time.Now(); v := time.Since(t).Milliseconds(); v >= 0`, `true`},
+		{"time.Time.Sub", `import "time"; a := time.Unix(1700000000, 0); b := a.Add(36*time.Hour); v := b.Sub(a); v == 36*time.Hour`, `true`},
+		{"time.Time.Before", `import "time"; a := time.Unix(0, 0); b := a.Add(time.Second); x := a.Before(b); y := a.After(b); z := a.Equal(a); x; y; z`, `true false true`},
+		{"time.Time.Unix", `import "time"; import "fmt"; v := fmt.Sprint(time.Unix(4102444800, 0).Unix()); v`, `4102444800`},
+		{"time.Time.Format", `import "time"; v := time.Unix(0, 0).UTC().Format(time.RFC3339); v`, `1970-01-01T00:00:00Z`},
+		{"time.Parse", `import "time"; import "fmt"; t, err := time.Parse(time.DateOnly, "2024-02-29"); v := fmt.Sprint(t.Unix()); v; err`, `1709164800 nil`},
+		{"time.Parse/error", `import "time"; _, err := time.Parse(time.DateOnly, "x"); err != nil`, `true`},
+		{"time.UnixMilli", `import "time"; v := time.UnixMilli(1500).UTC().Format(time.TimeOnly); v`, `00:00:01`},
+		{"time.AfterFunc", `import "time"; time.AfterFunc(0, func() { println("tick") }); println("wait"); time.Sleep(0)`, ";wait\ntick\n"},
+		{"time.AfterFunc/Stop", `import "time"; t := time.AfterFunc(0, func() { println("tick") }); v := t.Stop(); time.Sleep(0); v`, `true`},
+		{"time.AfterFunc/Reset", `import "time"; n := 0; var t *time.Timer; t = time.AfterFunc(0, func() { n++; if n < 3 { t.Reset(0) } }); for i := 0; i < 5; i++ { time.Sleep(0) }; n`, `3`},
 
 		{"maps.Clone", `import "golang.org/x/exp/maps"; a := map[string]int{"k":40}; b := maps.Clone(a); c := maps.Clone(a); c["k"] = 42; a; b; c`, `map[k:40] map[k:40] map[k:42]`},
 		{"maps.Keys", `import "golang.org/x/exp/maps"; m := map[string]int{"k":40,"v":2}; n := maps.Keys(m); n`, `[k v]`},
@@ -85,6 +167,16 @@ func TestBuiltins(t *testing.T) {
 		{"strconv.ParseInt/error", `import "strconv"; v, err := strconv.ParseInt("asdf",10,64); v, err!=nil`, `0 true`},
 		{"strconv.FormatInt", `import "strconv"; v := strconv.FormatInt(42,10); v`, `42`},
 
+		{"unicode.IsLetter", `import "unicode"; a, b := unicode.IsLetter('é'), unicode.IsLetter('1'); a; b`, `true false`},
+		{"unicode.IsSpace", `import "unicode"; a, b := unicode.IsSpace('\t'), unicode.IsSpace('x'); a; b`, `true false`},
+		{"unicode.ToUpper", `import "unicode"; r := unicode.ToUpper('ß'); s := string(unicode.ToUpper('é')); t := __type(r); s; t`, `É int32`},
+		{"utf8.RuneLen", `import "unicode/utf8"; a, b := utf8.RuneLen('a'), utf8.RuneLen('世'); a; b`, `1 3`},
+		{"utf8.DecodeRuneInString", `import "unicode/utf8"; r, n := utf8.DecodeRuneInString("世界"); s := string(r); s; n`, `世 3`},
+		{"utf8.DecodeRuneInString/invalid", `import "unicode/utf8"; r, n := utf8.DecodeRuneInString("\xff"); x := r == utf8.RuneError; x; n`, `true 1`},
+		{"utf8.ValidString", `import "unicode/utf8"; a, b := utf8.ValidString("héllo"), utf8.ValidString("\xffa"); a; b`, `true false`},
+		{"utf8.RuneCountInString", `import "unicode/utf8"; n := utf8.RuneCountInString("héllo"); n`, `5`},
+		{"utf8.AppendRune", `import "unicode/utf8"; b := utf8.AppendRune([]byte("a"), '世'); s := string(b); s`, `a世`},
+
 		{"os.Args", `import "os"; v := len(os.Args); v > 0`, `true`},
 	}
 
@@ -169,6 +261,14 @@ func TestBuiltins_Mocks(t *testing.T) {
 		assert(t, "res", res, `nil errReadFile`)
 	})
 
+	t.Run("os.ReadFile/ErrNotExist", func(t *testing.T) {
+		osReadFile = func(name string) ([]byte, error) {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+		res := testEval(t, `import "errors"; import "os"; _, err := os.ReadFile("test.txt"); errors.Is(err, os.ErrNotExist), errors.Is(err, os.ErrExist)`)
+		assert(t, "res", res, `true false`)
+	})
+
 	t.Run("os.WriteFile", func(t *testing.T) {
 		osWriteFile = func(name string, data []byte, perm os.FileMode) error {
 			assert(t, "name", name, "test.txt")
@@ -190,13 +290,64 @@ func TestBuiltins_Mocks(t *testing.T) {
 
 }
 
+func TestBuiltins_RandSeed(t *testing.T) {
+	eval := func(vm *VM) string {
+		res, err := vm.Eval(mapFS{}, "eval", `import "math/rand"; a := rand.Intn(1000); b := rand.Intn(1000); a; b`)
+		if err != nil {
+			t.Fatalf("Eval error: %v", err)
+		}
+		return fmt.Sprint(res)
+	}
+	a, b := New(WithRandSeed(7)), New(WithRandSource(rand.NewSource(7)))
+	assert(t, "a", eval(a), "[886 870]")
+	assert(t, "b", eval(b), "[886 870]")
+}
+
+func TestBuiltins_RandAttr(t *testing.T) {
+	r := &randRand{v: rand.New(rand.NewSource(0))}
+	r.GetAttr("Intn")
+	n := testing.AllocsPerRun(10, func() { r.GetAttr("Intn") })
+	assert(t, "allocs", n, 0.0)
+}
+
+type testClock struct{ now time.Time }
+
+func (c *testClock) Now() time.Time        { return c.now }
+func (c *testClock) Sleep(d time.Duration) { c.now = c.now.Add(d) }
+
+func TestBuiltins_Clock(t *testing.T) {
+	clock := &testClock{now: time.Unix(1700000000, 0)}
+	vm := New(WithClock(clock))
+	res, err := vm.Eval(mapFS{}, "eval", `import "time"
+		start := time.Now().Unix()
+		n := 0
+		t := time.AfterFunc(time.Hour, func() { n++; t.Reset(time.Hour) })
+		for i := 0; i < 3*60; i++ { time.Sleep(time.Minute) }
+		h := time.Duration.Hours(time.Since(time.Unix(start, 0)))
+		start; n; h`)
+	if err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+	assert(t, "res", fmt.Sprint(res), "[1.7e+09 3 3]")
+	assert(t, "clock", clock.now, time.Unix(1700000000+3*60*60, 0))
+}
+
 func TestBuiltins_error(t *testing.T) {
 	tests := []struct {
 		Name string
 		In   string
 		Err  string
 	}{
+		{"time.AfterFunc/panic", `import "time"; time.AfterFunc(0, func() { panic("panic") }); time.Sleep(0)`, `panic`},
 		{"slices.SortFunc/panic", `import "golang.org/x/exp/slices"; func f(a, b int) bool { panic("panic") }; s := []int{4,2,1,3}; slices.SortFunc(s, f)`, `panic`},
+		{"errors.Is/notError", `import "errors"; errors.Is(42, nil)`, `does not implement error`},
+		{"errors.As/notPointer", `import "errors"; var e error; errors.As(e, e)`, `errors.As: second argument must be a pointer`},
+		{"time.Since/nil", `import "time"; var t any; time.Since(t)`, `time.Since: nil is not a time.Time`},
+		{"time.Time.Sub/int", `import "time"; time.Now().Sub(42)`, `Time.Sub: 42 is not a time.Time`},
+		{"rand.Seed/float", `import "math/rand"; rand.Seed(1.5)`, `rand.Seed: seed 1.5 is not an int64`},
+		{"rand.NewSource/NaN", `import "math"; import "math/rand"; rand.NewSource(math.NaN())`, `rand.NewSource: seed NaN is not an int64`},
+		{"rand.New/source", `import "math/rand"; rand.New(42)`, `invalid source`},
+		{"rand.Shuffle/panic", `import "math/rand"; func f(i, j int) { panic("panic") }; rand.Shuffle(2, f)`, `panic`},
 		{"slices.SortStableFunc/panic", `import "golang.org/x/exp/slices"; func f(a, b int) bool { panic("panic") }; s := []int{4,2,1,3}; slices.SortStableFunc(s, f)`, `panic`},
 	}
 	for _, row := range tests {
@@ -4,6 +4,8 @@ const (
 	codeTODO = code(-(iota + 1))
 	codeBreak
 	codeContinue
+	codeGoto
+	codeLabel
 )
 const (
 	codePass = code(iota)
@@ -37,6 +39,7 @@ const (
 	codeNewMap
 	codeRange
 	codeIter
+	codeRangeStop
 	codeGet
 	codeGetOk
 	codeSet
@@ -83,17 +86,34 @@ const (
 	codeMake
 	codeCopy
 	codePanic
+	codeMin
+	codeMax
+	codeClear
+	codeCap
 
 	codeLocalMul
 	codeLocalDiv
 	codeLocalAdd
 	codeLocalSub
 	codeLocalIncDec
+
+	codeStructTag
+	codeStructEmbed
+
+	codeAddress
+	codeDeref
+	codeSetDeref
+	codeArray
+	codeEmbedMethods
+	codeAssert
+	codeAssertOk
 )
 
 var codeToString = map[code]string{
 	codeBreak:    "BREAK",
 	codeContinue: "CONTINUE",
+	codeGoto:     "GOTO",
+	codeLabel:    "LABEL",
 	codeTODO:     "TODO",
 
 	codePass: "PASS",
@@ -127,6 +147,7 @@ var codeToString = map[code]string{
 	codeNewMap:     "NEWMAP",
 	codeRange:      "RANGE",
 	codeIter:       "ITER",
+	codeRangeStop:  "RANGESTOP",
 	codeGet:        "GET",
 	codeGetOk:      "GETOK",
 	codeSet:        "SET",
@@ -177,12 +198,27 @@ var codeToString = map[code]string{
 	codeMake:  "MAKE",
 	codeCopy:  "COPY",
 	codePanic: "PANIC",
+	codeMin:   "MIN",
+	codeMax:   "MAX",
+	codeClear: "CLEAR",
+	codeCap:   "CAP",
 
 	codeLocalMul:    "LOCALMUL",
 	codeLocalDiv:    "LOCALDIV",
 	codeLocalAdd:    "LOCALADD",
 	codeLocalSub:    "LOCALSUB",
 	codeLocalIncDec: "LOCALINCDEC",
+
+	codeStructTag:   "STRUCTTAG",
+	codeStructEmbed: "STRUCTEMBED",
+
+	codeAddress:      "ADDRESS",
+	codeDeref:        "DEREF",
+	codeSetDeref:     "SETDEREF",
+	codeArray:        "ARRAY",
+	codeEmbedMethods: "EMBEDMETHODS",
+	codeAssert:       "ASSERT",
+	codeAssertOk:     "ASSERTOK",
 }
 
 func (c code) String() string {
@@ -8,7 +8,7 @@ import (
 )
 
 type code int
-type reg int
+type reg int64
 type pos uint64
 type instruction struct {
 	Code    code
@@ -26,13 +26,13 @@ func (i *instruction) String(g *lookup) string {
 	var p []string
 	p = append(p, i.Code.String())
 	switch i.Code {
-	case codePush, codeReturn, codeJumpFalse, codeJumpTrue, codeJump, codeIncDec, codeAnd, codeOr, codeStruct:
+	case codePush, codeReturn, codeMin, codeMax, codeJumpFalse, codeJumpTrue, codeJump, codeIncDec, codeAnd, codeOr, codeStruct:
 		p = append(p, fmt.Sprint(i.A))
 	case codeGlobalGet, codeGlobalSet, codeConst, codeGlobalRef, codeGetAttr, codeSetAttr, codeGlobalFunc, codeGlobalStruct:
 		p = append(p, g.Key(int(i.A)))
 	case codeGlobalZero:
 		p = append(p, g.Key(int(i.A)), Type(i.B).str(g))
-	case codeLocalGet, codeLocalSet:
+	case codeLocalGet, codeLocalSet, codeRangeStop:
 		p = append(p, "$"+fmt.Sprint(i.A))
 	case codeLocalZero:
 		p = append(p, "$"+fmt.Sprint(i.A), Type(i.B).str(g))
@@ -47,6 +47,10 @@ func (i *instruction) String(g *lookup) string {
 		p = append(p, "$"+fmt.Sprint(i.A), g.Key(int(i.B)), fmt.Sprintf("%d:%d", c1, c2))
 	case codeNewStruct:
 		p = append(p, g.Key(int(i.A)), fmt.Sprint(i.B))
+	case codeStructTag:
+		p = append(p, g.Key(int(i.A)), g.Key(int(i.B)))
+	case codeStructEmbed, codeEmbedMethods:
+		p = append(p, g.Key(int(i.A)))
 	// case codeNewLocalStruct:
 	// 	p = append(p, "$"+fmt.Sprint(i.A), fmt.Sprint(i.B))
 	case codeSetMethod:
@@ -57,9 +61,9 @@ func (i *instruction) String(g *lookup) string {
 		p = append(p, Type(i.A).str(g), fmt.Sprint(i.B))
 	case codeNewMap:
 		p = append(p, Type(i.A).str(g), Type(i.B).str(g), fmt.Sprint(i.C))
-	case codeZero, codeType, codeMake:
+	case codeZero, codeType, codeMake, codeArray:
 		p = append(p, Type(i.A).str(g))
-	case codeConvert, codeCast:
+	case codeConvert, codeCast, codeAssert, codeAssertOk:
 		p = append(p, Type(i.A).str(g))
 	case codeCall, codeAppend, codeCallVariadic:
 		p = append(p, fmt.Sprint(i.A), fmt.Sprint(i.B))
@@ -79,7 +83,7 @@ func newPos(l *lookup, fileName, funcName string, line, column int) pos {
 	// return struct{}{}
 	fileNameIdx := l.Index("#" + fileName)
 	funcNameIdx := l.Index("#" + funcName)
-	return pos((fileNameIdx << 48) | (funcNameIdx << 32) | (line << 16) | column)
+	return pos(fileNameIdx)<<48 | pos(funcNameIdx)<<32 | pos(line)<<16 | pos(column)
 }
 
 func (p pos) IsZero() bool {
@@ -116,16 +120,19 @@ func newGlobals() *lookup {
 
 func loadBuiltins(g *VM) {
 	loadMath(g.globals)
-	loadMathRand(g.globals)
+	loadMathBits(g.globals)
+	loadMathRand(g)
 	loadFmt(g.globals)
 	loadStrings(g.globals)
+	loadUnicode(g.globals)
 	loadErrors(g.globals)
 	loadBuiltin(g.globals)
-	loadTime(g.globals)
+	loadTime(g)
 	loadMaps(g.globals)
 	loadSlices(g)
 	loadOs(g)
 	loadStrconv(g)
+	loadCoroutine(g.globals)
 }
 
 type compiler struct {
@@ -137,8 +144,14 @@ type compiler struct {
 	cur         *token
 	Imports     map[string]string // alias -> package
 	Optimize    bool
-	Returns     []int
+	Returns     [][]*token // result types of the funcs being compiled
 	FuncName    string
+	Instances   *[]instruction    // generic instances, run before the package
+	TypeParams  map[string]string // type parameter -> global holding its type
+	label       string            // label of the next for, range or switch
+	ranges      []int             // iterator slots of the enclosing range loops
+	labelRanges map[reg]int       // range loops enclosing each label, see rangeExits
+	consts      map[int]*constant // local constants by slot, see constant
 }
 
 func compilePkgs(g *lookup, pkgs []*token, optimize bool) (ins []instruction, slots int, err error) {
@@ -177,8 +190,12 @@ func (c *compiler) run(tok *token) (ins []instruction, slots int, err error) {
 			err = fmt.Errorf("%v: %v", c.cur.Pos, r)
 		}
 	}()
+	var instances []instruction
+	c.Instances = &instances
+	c.declare(tok.Tokens)
 	res := c.optimize(c.compileAll(tok.Tokens))
-	return res, c.Locals.Cap(), nil
+	c.resolveLabels(res)
+	return append(instances, res...), c.Locals.Cap(), nil
 }
 
 func (c *compiler) isLocal() bool {
@@ -265,6 +282,7 @@ var convMap = map[string]Type{
 	"int32":   TypeInt32,
 	"int64":   TypeInt32,
 	"int16":   TypeInt32,
+	"float32": TypeFloat32,
 	"float64": TypeFloat64,
 	"string":  TypeString,
 	"[]":      TypeSlice,
@@ -280,10 +298,20 @@ var builtinMap = map[string]code{
 	"append": codeAppend,
 	"panic":  codePanic,
 	"copy":   codeCopy,
+	"min":    codeMin,
+	"max":    codeMax,
+	"clear":  codeClear,
+	"cap":    codeCap,
 }
 
 func (c *compiler) compile(tok *token) []instruction {
 	c.cur = tok
+	switch tok.Symbol {
+	case "(name)", ".", "call", "negate", "!", "complement", "<", ">", "<=", ">=", "==", "!=", "|", "^", "&", "<<", ">>", "+", "-", "*", "/", "%", "&&", "||":
+		if k := c.constant(tok); k != nil {
+			return c.pushConst(k)
+		}
+	}
 	var res []instruction
 	switch tok.Symbol {
 	case "(int)":
@@ -327,10 +355,13 @@ func (c *compiler) compile(tok *token) []instruction {
 			res = append(res, instruction{Code: codeSet})
 		} else if arg.Symbol == "." {
 			const indexItem, indexKey = 0, 1
+			promote := c.promote(arg.Tokens[indexItem], arg.Tokens[indexKey].Text)
 			res = append(res, c.compile(arg.Tokens[indexItem])...)
+			res = append(res, promote...)
 			res = append(res, instruction{Code: codeGetAttr, A: reg(c.Globals.Index(arg.Tokens[indexKey].Text))})
 			res = append(res, todo...)
 			res = append(res, c.compile(arg.Tokens[indexItem])...)
+			res = append(res, promote...)
 			res = append(res, instruction{Code: codeSetAttr, A: reg(c.Globals.Index(arg.Tokens[indexKey].Text))})
 		} else {
 			getter := codeGlobalGet
@@ -359,22 +390,41 @@ func (c *compiler) compile(tok *token) []instruction {
 		for i := 0; i < len(tok.Tokens[0].Tokens); i++ {
 			target := tok.Tokens[0].Tokens[i]
 			key := target.Text
-			value := values[i]
-			res = append(res, c.compile(value)...)
+			k := c.constant(values[i])
+			if k != nil && len(target.Tokens) > 0 {
+				k = c.convertType(k, typeFromToken(c, target.Tokens[0]), target.Tokens[0])
+			}
 
 			code := codeGlobalSet
 			var idx int
 			if c.isLocal() {
 				code = codeLocalSet
 				idx = c.Shadow(key)
+				if c.consts == nil {
+					c.consts = map[int]*constant{}
+				}
+				c.consts[idx] = k
 			} else {
 				lookup := c.Globals
 				key = c.expPrefix(key)
 				idx = lookup.Index(key)
+				if k != nil {
+					c.Globals.Set(constKey(key), Wrap(k))
+				} else {
+					c.unconst(key, idx)
+				}
+			}
+			if k == nil {
+				res = append(res, c.compile(values[i])...)
+			} else if k.overflows() { // only usable in constant expressions
+				continue
+			} else {
+				res = append(res, c.pushConst(k)...)
 			}
 			res = append(res, instruction{Code: code, A: reg(idx)})
 		}
 	case ":=", "var":
+		c.checkConsts(tok)
 		values := c.compile(tok.Tokens[1])
 		res = append(res, values...)
 		if len(values) == 0 {
@@ -393,6 +443,17 @@ func (c *compiler) compile(tok *token) []instruction {
 					key = c.expPrefix(key)
 					idx = lookup.Index(key)
 				}
+				c.unconst(key, idx)
+				c.setVarType(idx, typ, true)
+				if typ.isValue() && typ.base() == TypeSlice {
+					set := codeGlobalSet
+					if code == codeLocalZero {
+						set = codeLocalSet
+					}
+					res = append(res, c.zero(target.Tokens[0])...)
+					res = append(res, instruction{Code: set, A: reg(idx)})
+					continue
+				}
 				res = append(res, instruction{Code: code, A: reg(idx), B: reg(typ)})
 			}
 			break
@@ -413,16 +474,36 @@ func (c *compiler) compile(tok *token) []instruction {
 				key = c.expPrefix(key)
 				idx = lookup.Index(key)
 			}
+			c.unconst(key, idx)
+			vs := plural(tok.Tokens[1]).Tokens
+			if len(vs) != len(tok.Tokens[0].Tokens) {
+				vs = nil
+			}
 			if len(values) > 0 && len(target.Tokens) > 0 {
 				typ := typeFromToken(c, target.Tokens[0])
-				if slices.Contains([]Type{TypeUint8, TypeInt32, TypeFloat64}, typ) {
+				c.setVarType(idx, typ, true)
+				if vs != nil {
+					c.implements(vs[len(vs)-i], typ)
+				}
+				if typ.named() > 0 || typ.isInterface() || slices.Contains([]Type{TypeUint8, TypeInt8, TypeUint32, TypeInt32, TypeFloat32, TypeFloat64}, typ) {
 					res = append(res, instruction{Code: codeCast, A: reg(typ)})
 				}
+			} else if vs != nil {
+				typ, known := c.staticType(vs[len(vs)-i])
+				if typ == untypedInt {
+					typ = TypeInt32
+				}
+				c.setVarType(idx, typ, known)
+			} else {
+				c.setVarType(idx, 0, false)
 			}
 			res = append(res, instruction{Code: code, A: reg(idx)})
 		}
 
 	case "function":
+		if len(tok.Tokens) > 2 { // generic, see declare
+			break
+		}
 		target := tok.Tokens[0]
 		c.FuncName = c.pkgPrefix(target.Text)
 		res = append(res, c.compile(tok.Tokens[1])...)
@@ -439,31 +520,7 @@ func (c *compiler) compile(tok *token) []instruction {
 	case "=":
 		res = append(res, c.compile(tok.Tokens[1])...)
 		for i := 1; i <= len(tok.Tokens[0].Tokens); i++ {
-			arg := tok.Tokens[0].Tokens[len(tok.Tokens[0].Tokens)-i]
-			if arg.Text == "_" {
-				res = append(res, instruction{Code: codePop})
-				continue
-			} else if arg.Symbol == "index" {
-				const indexItem, indexKey = 0, 1
-				res = append(res, c.compile(arg.Tokens[indexItem])...)
-				res = append(res, c.compile(arg.Tokens[indexKey])...)
-				res = append(res, instruction{Code: codeSet})
-			} else if arg.Symbol == "." {
-				const indexItem, indexKey = 0, 1
-				res = append(res, c.compile(arg.Tokens[indexItem])...)
-				res = append(res, instruction{Code: codeSetAttr, A: reg(c.Globals.Index(arg.Tokens[indexKey].Text))})
-			} else {
-				code := codeGlobalSet
-				lookup := c.Globals
-				key := arg.Text
-				if c.Locals.Exists(key) {
-					code = codeLocalSet
-					lookup = c.Locals
-				} else {
-					key = c.expPrefix(key)
-				}
-				res = append(res, instruction{Code: code, A: reg(lookup.Index(key))})
-			}
+			res = append(res, c.store(tok.Tokens[0].Tokens[len(tok.Tokens[0].Tokens)-i])...)
 		}
 	case "true", "false", "nil":
 		res = append(res, instruction{Code: codeConst, A: reg(c.Globals.Index(tok.Text))})
@@ -471,6 +528,8 @@ func (c *compiler) compile(tok *token) []instruction {
 		key := c.expPrefix(tok.Text)
 		if tok.Text == "$" {
 			res = append(res, instruction{Code: codeGlobalGet, A: reg(c.Globals.Index("$"))})
+		} else if k, ok := c.TypeParams[tok.Text]; ok {
+			res = append(res, instruction{Code: codeGlobalGet, A: reg(c.Globals.Index(k))})
 		} else if c.isLocal() && c.Globals.Exists(c.FuncName+"."+tok.Text) {
 			res = append(res, instruction{Code: codeGlobalGet, A: reg(c.Globals.Index(c.FuncName + "." + tok.Text))})
 		} else if c.Locals.Exists(tok.Text) {
@@ -495,7 +554,17 @@ func (c *compiler) compile(tok *token) []instruction {
 				break
 			}
 		}
+		if left.Symbol == "." && left.Tokens[dotLeft].Symbol == "(name)" && !c.Locals.Exists(left.Tokens[dotLeft].Text) {
+			if pkg, ok := c.Imports[left.Tokens[dotLeft].Text]; ok {
+				key := pkg + "." + left.Tokens[dotRight].Text + "." + right.Text
+				if c.Globals.Exists(key) { // method expression
+					res = append(res, instruction{Code: codeGlobalGet, A: reg(c.Globals.Index(key))})
+					break
+				}
+			}
+		}
 		res = append(res, c.compile(left)...)
+		res = append(res, c.promote(left, right.Text)...)
 		res = append(res, instruction{Code: codeGetAttr, A: reg(c.Globals.Index(right.Text))})
 	case "slice":
 		const sliceObj, sliceBegin, sliceEnd = 0, 1, 2
@@ -505,8 +574,8 @@ func (c *compiler) compile(tok *token) []instruction {
 		res = append(res, instruction{Code: codeSlice})
 	case "func":
 		const funcArguments, funcReturns, funcBlock = 0, 1, 2
-		tmp := c.Locals
-		c.Locals = newLookup()
+		tmp, ranges, labelRanges, consts := c.Locals, c.ranges, c.labelRanges, c.consts
+		c.Locals, c.ranges, c.labelRanges, c.consts = newLookup(), nil, nil, nil
 		c.Begin()
 		arguments := len(tok.Tokens[funcArguments].Tokens)
 		var types []instruction
@@ -514,15 +583,16 @@ func (c *compiler) compile(tok *token) []instruction {
 			t := c.toType(arg.Tokens[0])
 			types = append(types, t)
 		}
-		for _, arg := range tok.Tokens[funcArguments].Tokens {
-			c.Locals.Index(arg.Text)
+		for i, arg := range tok.Tokens[funcArguments].Tokens {
+			c.Locals.setType(c.Locals.Index(arg.Text), Type(types[i].A), true)
 		}
 		if arguments > 0 && tok.Tokens[funcArguments].Tokens[arguments-1].Tokens[0].Text == "..." {
 			arguments = -arguments
 		}
 		returns := len(tok.Tokens[funcReturns].Tokens)
-		c.Returns = append(c.Returns, returns)
+		c.Returns = append(c.Returns, tok.Tokens[funcReturns].Tokens)
 		block := c.optimize(c.compile(tok.Tokens[funcBlock]))
+		c.resolveLabels(block)
 		res = append(res, instruction{Code: codeFunc,
 			A: joinParams(reg(arguments), reg(returns)),
 			B: reg(c.Locals.Cap()),
@@ -536,25 +606,44 @@ func (c *compiler) compile(tok *token) []instruction {
 		res = append(res, block...)
 		c.Returns = c.Returns[:len(c.Returns)-1]
 		c.End()
-		c.Locals = tmp
+		c.Locals, c.ranges, c.labelRanges, c.consts = tmp, ranges, labelRanges, consts
 	case "block", ",":
 		res = append(res, c.compileAll(tok.Tokens)...)
 	case "return":
 		if len(tok.Tokens) == 1 && tok.Tokens[0].Symbol == "call" {
 			returns := c.compileAll(tok.Tokens)
-			returns[len(returns)-1].B = reg(c.Returns[len(c.Returns)-1])
+			returns[len(returns)-1].B = reg(len(c.Returns[len(c.Returns)-1]))
 			res = append(res, returns...)
-			res = append(res, instruction{Code: codeReturn, A: reg(c.Returns[len(c.Returns)-1])})
+			res = append(res, c.rangeStops()...)
+			res = append(res, instruction{Code: codeReturn, A: reg(len(c.Returns[len(c.Returns)-1]))})
 			break
 		}
+		if n := len(c.Returns); n > 0 && len(c.Returns[n-1]) == len(tok.Tokens) {
+			for i, r := range c.Returns[n-1] {
+				c.implements(tok.Tokens[i], typeFromToken(c, r))
+			}
+		}
 		returns := c.compileAll(tok.Tokens)
 		res = append(res, returns...)
+		res = append(res, c.rangeStops()...)
 		res = append(res, instruction{Code: codeReturn, A: reg(len(tok.Tokens))})
 	case "call":
 		const callName, callArguments, callReturns = 0, 1, 2
+		if tok.Tokens[callName].Text == "new" && !c.Locals.Exists("new") {
+			res = append(res, c.newPointer(tok.Tokens[callArguments].Tokens)...)
+			break
+		}
+		if c.isImported(tok.Tokens[callName], "errors", "As") {
+			res = append(res, c.errorsAs(tok.Tokens[callName], tok.Tokens[callArguments].Tokens)...)
+			break
+		}
 		res = append(res, c.compileAll(tok.Tokens[callArguments].Tokens)...)
-		if slices.Contains([]string{"byte", "uint8", "int8", "int", "int32", "rune", "uint32", "uint", "int64", "uint64", "int16", "uint16", "float64", "string", "[]"}, tok.Tokens[callName].Symbol) {
-			res = append(res, instruction{Code: codeConvert, A: reg(convMap[tok.Tokens[callName].Symbol])})
+		if slices.Contains([]string{"byte", "uint8", "int8", "int", "int32", "rune", "uint32", "uint", "int64", "uint64", "int16", "uint16", "float32", "float64", "string", "[]"}, tok.Tokens[callName].Symbol) {
+			typ := convMap[tok.Tokens[callName].Symbol]
+			if typ == TypeSlice {
+				typ = typeFromToken(c, tok.Tokens[callName])
+			}
+			res = append(res, instruction{Code: codeConvert, A: reg(typ)})
 		} else if code := builtinMap[tok.Tokens[callName].Text]; code != 0 {
 			ellipsis := 0
 			args := tok.Tokens[callArguments].Tokens
@@ -564,10 +653,22 @@ func (c *compiler) compile(tok *token) []instruction {
 			res = append(res, instruction{Code: code, A: reg(len(args)), B: reg(ellipsis)})
 		} else {
 			fnc := c.compile(tok.Tokens[callName])
-			if tok.Tokens[callName].Symbol == "(name)" {
+			if g := c.generic(tok.Tokens[callName]); g != nil && g.decl.Symbol == "function" {
+				if types, ok := c.infer(g, tok.Tokens[callArguments].Tokens); ok {
+					fnc = []instruction{{Code: codeGlobalGet, A: reg(c.instantiate(g, types))}}
+				}
+			}
+			if sym := tok.Tokens[callName].Symbol; (sym == "(name)" || sym == "." || sym == "index") && len(fnc) == 1 && fnc[0].Code == codeGlobalGet {
 				typ := c.Globals.Read(int(fnc[0].A))
 				if typ.t == typeType {
-					res = append(res, instruction{Code: codeConvert, A: reg(typ.Int())})
+					res = append(res, instruction{Code: codeConvert, A: reg(typ.typeValue())})
+					break
+				}
+				if t := c.structValue(int(fnc[0].A)); t.isInterface() {
+					if args := tok.Tokens[callArguments].Tokens; len(args) == 1 {
+						c.implements(args[0], t)
+					}
+					res = append(res, instruction{Code: codeCast, A: reg(t)})
 					break
 				}
 			}
@@ -618,6 +719,7 @@ func (c *compiler) compile(tok *token) []instruction {
 		c.End()
 	case "switch":
 		const switchStmt, switchCases, switchDefault = 0, 1, 2
+		label := c.takeLabel()
 		c.Begin()
 		stmt := c.compile(tok.Tokens[switchStmt])
 		res = append(res, stmt...)
@@ -639,8 +741,7 @@ func (c *compiler) compile(tok *token) []instruction {
 			c.Begin()
 			csBlock := c.optimize(c.compileAll(cs.Tokens[caseBlock].Tokens))
 			for n, ins := range csBlock {
-				switch ins.Code {
-				case codeBreak:
+				if ins.Code == codeBreak && (ins.A == 0 || ins.A == label) {
 					csBlock[n].Code, csBlock[n].A = codeJump, reg((len(csBlock)-n)+len(out)+len(defBlock))
 				}
 			}
@@ -662,6 +763,7 @@ func (c *compiler) compile(tok *token) []instruction {
 
 	case "for":
 		const forInit, forCond, forPost, forBlock = 0, 1, 2, 3
+		label := c.takeLabel()
 		c.Begin()
 		res = append(res, c.compile(tok.Tokens[forInit])...)
 		cond := c.optimize(c.compile(tok.Tokens[forCond]))
@@ -671,6 +773,9 @@ func (c *compiler) compile(tok *token) []instruction {
 			res = append(res, instruction{Code: codeJump, A: reg((len(block) + len(post)))})
 		}
 		for n, ins := range block {
+			if ins.A != 0 && ins.A != label {
+				continue
+			}
 			switch ins.Code {
 			case codeBreak:
 				block[n].Code, block[n].A = codeJump, reg((len(block)-n)+len(post)+len(cond))
@@ -696,19 +801,26 @@ func (c *compiler) compile(tok *token) []instruction {
 		if tok.Tokens[makeType].Symbol == "(name)" {
 			ref := c.compile(tok.Tokens[makeType])
 			val := c.Globals.Read(int(ref[0].A))
-			typ = Type(val.Int())
+			typ = val.typeValue()
 		} else {
 			typ = typeFromToken(c, tok.Tokens[makeType])
 
 		}
 		switch typ.base() {
 		case TypeSlice:
-			res = append(res, c.compile(tok.Tokens[makeLen])...)
-			res = append(res, instruction{Code: codeMake, A: reg(typ.value())})
+			for _, t := range tok.Tokens[makeLen:] {
+				res = append(res, c.compile(t)...)
+			}
+			res = append(res, instruction{Code: codeMake, A: reg(typ.value()), B: reg(len(tok.Tokens) - makeLen)})
 		case TypeMap:
+			for _, t := range tok.Tokens[makeLen:] {
+				res = append(res, c.compile(t)...)
+				res = append(res, instruction{Code: codePop})
+			}
 			kt, vt := typ.pair()
 			res = append(res, instruction{Code: codeNewMap, A: reg(kt), B: reg(vt), C: 0})
 		}
+		res = append(res, c.toNamed(typ)...)
 	case "package":
 		c.PackageName = tok.Tokens[0].Text
 		c.ExportName = tok.Tokens[0].Text
@@ -724,20 +836,31 @@ func (c *compiler) compile(tok *token) []instruction {
 	case "[]":
 		const newType, newData = 0, 1
 		typ := sliceType(typeFromToken(c, tok.Tokens[newType]))
-		res = append(res, c.toData(typ, tok.Tokens[newData])...)
+		res = append(res, c.toData(typ, tok, tok.Tokens[newData])...)
 	case "map":
 		const newKeyType, newValueType, newData = 0, 1, 2
-		typ := mapType(typeFromToken(c, tok.Tokens[newKeyType]), typeFromToken(c, tok.Tokens[newValueType]))
-		res = append(res, c.toData(typ, tok.Tokens[newData])...)
+		kt := typeFromToken(c, tok.Tokens[newKeyType])
+		typ := mapType(kt, typeFromToken(c, tok.Tokens[newValueType]))
+		if data := tok.Tokens[newData]; data.Symbol == ";" || data.Symbol == ":" {
+			res = append(res, c.mapData(typ, kt, tok, data)...)
+		} else {
+			res = append(res, c.toData(typ, tok, data)...)
+		}
 	case "range":
+		label := c.takeLabel()
 		c.Begin()
 		const rangeKey, rangeValue, rangeItem, rangeBlock = 0, 1, 2, 3
 		res = append(res, c.compile(tok.Tokens[rangeItem])...)
 		r := c.Locals.Index(tok.Pos.String())
 		k := c.Locals.Index(tok.Tokens[rangeKey].Text)
 		v := c.Locals.Index(tok.Tokens[rangeValue].Text)
+		c.ranges = append(c.ranges, r)
 		block := c.optimize(c.compile(tok.Tokens[rangeBlock]))
+		c.ranges = c.ranges[:len(c.ranges)-1]
 		for n, ins := range block {
+			if ins.A != 0 && ins.A != label {
+				continue
+			}
 			switch ins.Code {
 			case codeBreak:
 				block[n].Code, block[n].A = codeJump, reg(len(block)-n)
@@ -748,14 +871,47 @@ func (c *compiler) compile(tok *token) []instruction {
 		res = append(res, instruction{Code: codeRange, A: reg(r), B: reg(len(block))})
 		res = append(res, block...)
 		res = append(res, instruction{Code: codeIter, A: reg(r), B: joinParams(reg(k), reg(v)), C: reg(-(len(block) + 1))})
+		res = append(res, instruction{Code: codeRangeStop, A: reg(r)})
 		c.End()
-	case "break":
-		res = append(res, instruction{Code: codeBreak})
-	case "continue":
-		res = append(res, instruction{Code: codeContinue})
+	case "break", "continue", "goto":
+		code := map[string]code{"break": codeBreak, "continue": codeContinue, "goto": codeGoto}[tok.Symbol]
+		var label reg
+		if len(tok.Tokens) > 0 {
+			label = c.labelID(tok.Tokens[0].Text)
+			res = append(res, c.rangeExits(code, label)...)
+		}
+		res = append(res, instruction{Code: code, A: label})
+	case "label":
+		label := c.labelID(tok.Text)
+		res = append(res, instruction{Code: codeLabel, A: label})
+		if c.labelRanges == nil {
+			c.labelRanges = map[reg]int{}
+		}
+		c.labelRanges[label] = len(c.ranges)
+		if len(tok.Tokens) > 0 && tok.Tokens[0].Symbol == "range" {
+			c.labelRanges[label]++ // its own iterator stops when the loop ends
+		}
+		if len(tok.Tokens) > 0 {
+			c.label = tok.Text
+			res = append(res, c.compile(tok.Tokens[0])...)
+			c.label = ""
+		}
 
+	case "assert", "assertOk":
+		const assertValue, assertType = 0, 1
+		res = append(res, c.compile(tok.Tokens[assertValue])...)
+		code := codeAssert
+		if tok.Symbol == "assertOk" {
+			code = codeAssertOk
+		}
+		res = append(res, instruction{Code: code, A: reg(typeFromToken(c, tok.Tokens[assertType]))})
 	case "index", "indexOk":
 		const indexItem, indexKey = 0, 1
+		if g := c.generic(tok.Tokens[indexItem]); g != nil {
+			idx := c.instantiate(g, c.typeArgs(tok.Tokens[indexKey]))
+			res = append(res, instruction{Code: codeGlobalGet, A: reg(idx)})
+			break
+		}
 		res = append(res, c.compile(tok.Tokens[indexItem])...)
 		res = append(res, c.compile(tok.Tokens[indexKey])...)
 		code := codeGet
@@ -771,6 +927,9 @@ func (c *compiler) compile(tok *token) []instruction {
 		res = append(res, instruction{Code: codeBitComplement})
 	case "type":
 		const typeName, typeStruct = 0, 1
+		if len(tok.Tokens) > 2 { // generic, see declare
+			break
+		}
 		ts := tok.Tokens[typeStruct].Symbol
 
 		key := tok.Tokens[typeName].Text
@@ -793,42 +952,96 @@ func (c *compiler) compile(tok *token) []instruction {
 		// 	setStruct = codeLocalSet
 		// }
 		if ts == "interface" {
+			decl := &ifaceDecl{}
 			res = append(res, instruction{Code: codeStruct, A: 0})
 			res = append(res, instruction{Code: setStruct, A: reg(idx)})
-			for i := 0; i < len(tok.Tokens[typeStruct].Tokens); i += 3 {
+			for ms := tok.Tokens[typeStruct].Tokens; len(ms) > 0; {
+				if ms[0].Symbol == "embed" {
+					embed := c.compile(ms[0].Tokens[0])[0].A
+					decl.embeds = append(decl.embeds, int(embed))
+					res = append(res, instruction{Code: getStruct, A: reg(idx)})
+					res = append(res, instruction{Code: codeEmbedMethods, A: embed})
+					ms = ms[1:]
+					continue
+				}
+				decl.methods = append(decl.methods, ms[0].Text)
 				res = append(res, instruction{Code: codeZero, A: reg(TypeFunc)})
 				res = append(res, instruction{Code: getStruct, A: reg(idx)})
-				res = append(res, instruction{Code: codeSetMethod, A: reg(c.Globals.Index(tok.Tokens[typeStruct].Tokens[i].Text))})
+				res = append(res, instruction{Code: codeSetMethod, A: reg(c.Globals.Index(ms[0].Text))})
+				ms = ms[3:]
 			}
+			c.Globals.Set(interfaceKey(key), Wrap(decl))
 			break
 		}
-		if ts == "bool" || ts == "byte" || ts == "uint8" || ts == "int8" || ts == "int" || ts == "int32" || ts == "rune" || ts == "uint32" || ts == "uint" || ts == "float64" || ts == "string" || ts == "int16" || ts == "int64" || ts == "uint16" || ts == "uint64" {
-			c.Globals.Write(int(reg(idx)), newType(convMap[ts]))
+		if ts == "bool" || ts == "byte" || ts == "uint8" || ts == "int8" || ts == "int" || ts == "int32" || ts == "rune" || ts == "uint32" || ts == "uint" || ts == "float32" || ts == "float64" || ts == "string" || ts == "int16" || ts == "int64" || ts == "uint16" || ts == "uint64" {
+			c.Globals.Write(int(reg(idx)), newType(namedType(idx, convMap[ts])))
 			break
 		}
 		if ts == "struct" {
 			for i := 0; i < len(tok.Tokens[typeStruct].Tokens); i += 2 {
 				t := tok.Tokens[typeStruct].Tokens[i]
 				res = append(res, instruction{Code: codeGlobalRef, A: reg(c.Globals.Index(t.Text))})
-				t = tok.Tokens[typeStruct].Tokens[i+1]
-				res = append(res, instruction{Code: codeZero, A: reg(typeFromToken(c, t))})
+				res = append(res, c.zero(tok.Tokens[typeStruct].Tokens[i+1])...)
 			}
 			res = append(res, instruction{Code: codeStruct, A: reg(len(tok.Tokens[typeStruct].Tokens))})
+			c.declareStruct(key, tok.Tokens[typeStruct])
+			for i := 0; i < len(tok.Tokens[typeStruct].Tokens); i += 2 {
+				t := tok.Tokens[typeStruct].Tokens[i]
+				if t.Symbol == "embed" {
+					res = append(res, instruction{Code: codeStructEmbed, A: reg(c.Globals.Index(t.Text))})
+				}
+				if len(t.Tokens) == 0 {
+					continue
+				}
+				tag := t.Tokens[0]
+				c.Globals.Set(tag.Text, String(tag.Unquote()))
+				res = append(res, instruction{Code: codeStructTag, A: reg(c.Globals.Index(t.Text)), B: reg(c.Globals.Index(tag.Text))})
+			}
 			res = append(res, instruction{Code: setStruct, A: reg(idx)})
 			break
 		}
-		typ := typeFromToken(c, tok.Tokens[typeStruct])
+		typ := namedType(idx, typeFromToken(c, tok.Tokens[typeStruct]))
 		c.Globals.Write(int(reg(idx)), newType(typ))
+		if ts == "array" {
+			res = append(res, c.zero(tok.Tokens[typeStruct])...)
+			res = append(res, instruction{Code: codeConvert, A: reg(typ)})
+			res = append(res, instruction{Code: codeGlobalSet, A: reg(c.Globals.Index(zeroKey(key)))})
+		}
 
+	case "array":
+		const arrayData = 2
+		res = append(res, c.toArray(tok, tok.Tokens[arrayData])...)
+	case "address":
+		res = append(res, c.compile(tok.Tokens[0])...)
+		if n := len(res) - 1; n >= 0 && res[n].Code == codeNewStruct {
+			res[n].C = 0
+		} else {
+			res = append(res, instruction{Code: codeAddress})
+		}
+	case "deref":
+		res = append(res, c.compile(tok.Tokens[0])...)
+		res = append(res, instruction{Code: codeDeref})
 	case "new":
 		const newType, newData = 0, 1
 		typ := typeFromToken(c, tok.Tokens[newType])
-		res = append(res, c.toData(typ, tok.Tokens[newData])...)
+		res = append(res, c.toData(typ, tok.Tokens[newType], tok.Tokens[newData])...)
 	case "method":
 		const methodType, methodName, methodFunc = 0, 1, 2
+		if isGenericMethod(tok) && c.TypeParams == nil { // see declare
+			break
+		}
 		c.FuncName = c.pkgPrefix(tok.Tokens[methodType].Text) + "." + tok.Tokens[methodName].Text
 		res = append(res, c.compile(tok.Tokens[methodFunc])...)
-		res = append(res, instruction{Code: codeGlobalGet, A: reg(c.Globals.Index(c.expPrefix(tok.Tokens[methodType].Text)))})
+		key := c.expPrefix(tok.Tokens[methodType].Text)
+		if typ := c.Globals.Get(key); typ.t == typeType { // see Value.method
+			idx := c.Globals.Index(key + "." + tok.Tokens[methodName].Text)
+			c.Globals.setMethod(c.Globals.Index(key), c.Globals.Index(tok.Tokens[methodName].Text), idx, ptrReceiver(tok))
+			res = append(res, instruction{Code: codeGlobalSet, A: reg(idx)})
+			c.FuncName = ""
+			break
+		}
+		c.Globals.setMethod(c.Globals.Index(key), c.Globals.Index(tok.Tokens[methodName].Text), -1, ptrReceiver(tok))
+		res = append(res, instruction{Code: codeGlobalGet, A: reg(c.Globals.Index(key))})
 		res = append(res, instruction{Code: codeSetMethod,
 			A: reg(c.Globals.Index(tok.Tokens[methodName].Text)),
 		})
@@ -846,27 +1059,26 @@ func (c *compiler) compile(tok *token) []instruction {
 	return res
 }
 
-func (c *compiler) toData(typ Type, data *token) []instruction {
+// toData builds the literal data of type typ. tok is the type when it is
+// spelled out, so elided array literals keep their length, see toArray.
+func (c *compiler) toData(typ Type, tok, data *token) []instruction {
+	if tok != nil && tok.Symbol == "array" && data.Symbol == ";" {
+		return c.toArray(tok, data)
+	}
 	var res []instruction
 	switch data.Symbol {
 	case ";", ":":
 		switch typ.base() {
 		case TypeMap:
-			kt, vt := typ.pair()
-			for i, t := range data.Tokens {
-				if i%2 == 0 {
-					res = append(res, c.compile(t)...)
-				} else {
-					res = append(res, c.toData(vt, t)...)
-				}
-			}
-			res = append(res, instruction{Code: codeNewMap, A: reg(kt), B: reg(vt), C: reg(len(data.Tokens))})
+			kt, _ := typ.pair()
+			res = append(res, c.mapData(typ, kt, tok, data)...)
 		case TypeSlice:
 			dt := typ.value()
 			for _, t := range data.Tokens {
-				res = append(res, c.toData(dt, t)...)
+				res = append(res, c.toData(dt, typeArg(tok, "[]", 0), t)...)
 			}
 			res = append(res, instruction{Code: codeNewSlice, A: reg(dt), B: reg(len(data.Tokens))})
+			res = append(res, c.toNamed(typ)...)
 		case TypeStruct:
 			st := typ.value()
 			for i := 0; i < len(data.Tokens); i += 2 {
@@ -874,7 +1086,11 @@ func (c *compiler) toData(typ Type, data *token) []instruction {
 				res = append(res, instruction{Code: codeGlobalRef, A: reg(c.Globals.Index(t.Text))})
 				res = append(res, c.compile(data.Tokens[i+1])...)
 			}
-			res = append(res, instruction{Code: codeNewStruct, A: reg(st), B: reg(len(data.Tokens))})
+			value := 0
+			if typ.isValue() {
+				value = 1
+			}
+			res = append(res, instruction{Code: codeNewStruct, A: reg(st), B: reg(len(data.Tokens)), C: reg(value)})
 		default:
 			panicf("untyped data")
 		}
@@ -884,6 +1100,194 @@ func (c *compiler) toData(typ Type, data *token) []instruction {
 	return res
 }
 
+// typeArg is the i-th type in the type tok when it is a sym type, or nil.
+func typeArg(tok *token, sym string, i int) *token {
+	if tok == nil || tok.Symbol != sym {
+		return nil
+	}
+	return tok.Tokens[i]
+}
+
+// toNamed converts a new slice or map to its named or array type.
+func (c *compiler) toNamed(typ Type) []instruction {
+	if typ.named() == 0 && !typ.isValue() {
+		return nil
+	}
+	return []instruction{{Code: codeConvert, A: reg(typ)}}
+}
+
+// toArray builds the [N]T literal data, filling the missing elements with
+// zero values.
+func (c *compiler) toArray(tok, data *token) []instruction {
+	const arrayLen, arrayElem = 0, 1
+	typ, elem := typeFromToken(c, tok), tok.Tokens[arrayElem]
+	var res []instruction
+	for _, t := range data.Tokens {
+		res = append(res, c.toData(typ.value(), elem, t)...)
+	}
+	res = append(res, c.zero(elem)...)
+	res = append(res, c.compile(tok.Tokens[arrayLen])...)
+	return append(res, instruction{Code: codeArray, A: reg(typ), B: reg(len(data.Tokens))})
+}
+
+// zero pushes the zero value of the type tok.
+func (c *compiler) zero(tok *token) []instruction {
+	if tok.Symbol == "array" {
+		return c.toArray(tok, &token{Symbol: ";"})
+	}
+	typ := typeFromToken(c, tok)
+	if typ.isValue() && typ.base() == TypeSlice { // named array, see "type"
+		return []instruction{{Code: codeGlobalGet, A: reg(c.Globals.Index(zeroKey(typ.str(c.Globals))))}}
+	}
+	return []instruction{{Code: codeZero, A: reg(typ)}}
+}
+
+// mapData is a map literal, kt is the full key type as map types only keep
+// the base type of their keys.
+func (c *compiler) mapData(typ, kt Type, tok, data *token) []instruction {
+	var res []instruction
+	_, vt := typ.pair()
+	for i, t := range data.Tokens {
+		if i%2 == 0 {
+			res = append(res, c.toData(kt, typeArg(tok, "map", 0), t)...)
+		} else {
+			res = append(res, c.toData(vt, typeArg(tok, "map", 1), t)...)
+		}
+	}
+	res = append(res, instruction{Code: codeNewMap, A: reg(kt), B: reg(vt), C: reg(len(data.Tokens))})
+	return append(res, c.toNamed(typ)...)
+}
+
+// newPointer is new(T), a pointer to a zero valued struct.
+func (c *compiler) newPointer(args []*token) []instruction {
+	if len(args) != 1 {
+		panicf("new: wrong number of arguments")
+	}
+	if typ := typeFromToken(c, args[0]); typ.base() != TypeStruct {
+		panicf("new: %v is not a struct type", typ.str(c.Globals))
+	}
+	data := &token{Symbol: "new", Pos: args[0].Pos, Tokens: []*token{args[0], {Symbol: ";", Pos: args[0].Pos}}}
+	return c.compile(&token{Symbol: "address", Pos: args[0].Pos, Tokens: []*token{data}})
+}
+
+// isImported reports whether tok names the member name of package pkg.
+func (c *compiler) isImported(tok *token, pkg, name string) bool {
+	if tok.Symbol != "." || tok.Tokens[0].Symbol != "(name)" || c.Locals.Exists(tok.Tokens[0].Text) {
+		return false
+	}
+	return c.Imports[tok.Tokens[0].Text] == pkg && tok.Tokens[1].Text == name
+}
+
+// errorsAs compiles errors.As(err, &target), which passes the current value
+// of target for its type and stores the match back into it.
+func (c *compiler) errorsAs(fnc *token, args []*token) (res []instruction) {
+	if len(args) != 2 || args[1].Symbol != "address" {
+		panicf("errors.As: second argument must be a pointer to a variable")
+	}
+	target := args[1].Tokens[0]
+	res = append(res, c.compile(args[0])...)
+	res = append(res, c.compile(target)...)
+	res = append(res, c.compile(fnc)...)
+	res = append(res, instruction{Code: codeCall, A: 2, B: 2})
+	return append(res, c.store(target)...)
+}
+
+// store pops the top of the stack into the assignment target arg.
+func (c *compiler) store(arg *token) (res []instruction) {
+	if arg.Text == "_" {
+		res = append(res, instruction{Code: codePop})
+	} else if arg.Symbol == "index" {
+		const indexItem, indexKey = 0, 1
+		res = append(res, c.compile(arg.Tokens[indexItem])...)
+		res = append(res, c.compile(arg.Tokens[indexKey])...)
+		res = append(res, instruction{Code: codeSet})
+	} else if arg.Symbol == "." {
+		const indexItem, indexKey = 0, 1
+		res = append(res, c.compile(arg.Tokens[indexItem])...)
+		res = append(res, c.promote(arg.Tokens[indexItem], arg.Tokens[indexKey].Text)...)
+		res = append(res, instruction{Code: codeSetAttr, A: reg(c.Globals.Index(arg.Tokens[indexKey].Text))})
+	} else if arg.Symbol == "deref" {
+		res = append(res, c.compile(arg.Tokens[0])...)
+		res = append(res, instruction{Code: codeSetDeref})
+	} else {
+		code := codeGlobalSet
+		lookup := c.Globals
+		key := arg.Text
+		if c.Locals.Exists(key) {
+			code = codeLocalSet
+			lookup = c.Locals
+		} else {
+			key = c.expPrefix(key)
+		}
+		res = append(res, instruction{Code: code, A: reg(lookup.Index(key))})
+	}
+	return res
+}
+
+// zeroKey is the hidden global holding the zero value of the named array key.
+func zeroKey(key string) string { return key + "{0}" }
+
+// labelID identifies the label name in jumps, 0 is the innermost for,
+// range or switch.
+func (c *compiler) labelID(name string) reg {
+	return reg(c.Globals.Index(name+":")) + 1
+}
+
+func (c *compiler) labelName(id reg) string {
+	return strings.TrimSuffix(c.Globals.Key(int(id-1)), ":")
+}
+
+// takeLabel returns the label of the for, range or switch being compiled,
+// or -1 when it has none.
+func (c *compiler) takeLabel() reg {
+	if c.label == "" {
+		return -1
+	}
+	label := c.labelID(c.label)
+	c.label = ""
+	return label
+}
+
+// resolveLabels turns the gotos and labels of a function body into jumps,
+// break and continue to an enclosing label are already jumps by now.
+func (c *compiler) resolveLabels(block []instruction) {
+	labels := map[reg]int{}
+	for n, ins := range block {
+		if ins.Code == codeLabel {
+			labels[ins.A] = n
+			block[n].Code, block[n].A = codePass, 0
+		}
+	}
+	for n, ins := range block {
+		switch {
+		case ins.Code == codeGoto:
+			at, ok := labels[ins.A]
+			if !ok {
+				panicf("label %v not defined", c.labelName(ins.A))
+			}
+			block[n].Code, block[n].A = codeJump, reg(at-n-1)
+		case (ins.Code == codeBreak || ins.Code == codeContinue) && ins.A != 0:
+			panicf("invalid %v label %v", strings.ToLower(ins.Code.String()), c.labelName(ins.A))
+		case ins.Code == codeRangeStop && ins.B != 0:
+			if at, ok := labels[ins.B]; ok && inRange(block, n, ins.A, at) {
+				block[n].Code, block[n].A = codePass, 0
+			}
+			block[n].B = 0
+		}
+	}
+}
+
+// inRange reports whether the instruction at is in the range loop over the
+// iterator slot r enclosing the instruction n.
+func inRange(block []instruction, n int, r reg, at int) bool {
+	for i := n - 1; i >= 0; i-- {
+		if ins := block[i]; ins.Code == codeRange && ins.A == r && n <= i+int(ins.B)+2 {
+			return i < at && at <= i+int(ins.B)+2
+		}
+	}
+	return false
+}
+
 func (c *compiler) toType(tok *token) instruction {
 	return instruction{Code: codeType, A: reg(typeFromToken(c, tok))}
 }
@@ -966,12 +1370,263 @@ func (c *compiler) doOptimize(in []instruction) []instruction {
 	return out
 }
 
+// interfaceKey is the hidden global marking key as an interface type, once
+// compiled it holds the ifaceDecl.
+func interfaceKey(key string) string { return key + "{}" }
+
+// structKey is the hidden global holding the structDecl of the struct key.
+func structKey(key string) string { return key + "{struct}" }
+
+// structDecl is the fields of a struct as declared, see promoted.
+type structDecl struct {
+	Object
+	fields []string
+	embeds []structEmbed
+}
+
+type structEmbed struct {
+	name string
+	idx  int // global index of the embedded struct, -1 when not a struct
+	ptr  bool
+}
+
+// declareStruct records the fields of the struct type tok declared as key.
+func (c *compiler) declareStruct(key string, tok *token) {
+	decl := &structDecl{}
+	for i := 0; i < len(tok.Tokens); i += 2 {
+		f := tok.Tokens[i]
+		decl.fields = append(decl.fields, f.Text)
+		if f.Symbol != "embed" {
+			continue
+		}
+		idx := -1
+		if e := elemType(tok.Tokens[i+1]); e.Symbol == "(name)" || e.Symbol == "." {
+			if t := typeFromToken(c, e); t.base() == TypeStruct && !t.isInterface() {
+				idx = int(t.value())
+			}
+		}
+		decl.embeds = append(decl.embeds, structEmbed{name: f.Text, idx: idx, ptr: tok.Tokens[i+1].Symbol == "*"})
+	}
+	c.Globals.Set(structKey(key), Wrap(decl))
+}
+
+// selection is the way to a field or method, see promoted.
+type selection struct {
+	idx  int      // global index of the struct holding it
+	path []string // embedded fields leading to that struct
+	ptr  bool     // whether the path goes through an embedded pointer
+}
+
+// promoted finds the field or method name of the struct at global idx the
+// way Go does, shallowest first. known is false when a struct on the way is
+// not known at compile time.
+func (c *compiler) promoted(idx int, name string) (sel selection, found, known bool) {
+	key := c.Globals.Index(name)
+	level, seen := []selection{{idx: idx}}, map[int]bool{idx: true}
+	for len(level) > 0 {
+		var hits, next []selection
+		for _, s := range level {
+			if s.idx < 0 || !c.Globals.Exists(structKey(c.Globals.Key(s.idx))) {
+				return selection{}, false, false
+			}
+			decl, ok := c.Globals.Get(structKey(c.Globals.Key(s.idx))).value.(*structDecl)
+			if !ok {
+				return selection{}, false, false
+			}
+			if _, ok := c.Globals.method(s.idx, key); ok || slices.Contains(decl.fields, name) {
+				hits = append(hits, s)
+				continue
+			}
+			for _, e := range decl.embeds {
+				if !seen[e.idx] {
+					seen[e.idx] = true
+					next = append(next, selection{idx: e.idx, path: append(slices.Clip(s.path), e.name), ptr: s.ptr || e.ptr})
+				}
+			}
+		}
+		switch len(hits) {
+		case 0:
+			level = next
+		case 1:
+			return hits[0], true, true
+		default:
+			panicf("ambiguous selector %v", name)
+		}
+	}
+	return selection{}, false, true
+}
+
+// promote selects the embedded fields leading to the promoted field or
+// method name of tok, when the struct type of tok is known at compile time.
+// Other values resolve it at run time, see structT.promoted.
+func (c *compiler) promote(tok *token, name string) []instruction {
+	t, ok := c.staticType(tok)
+	if !ok || t.base() != TypeStruct || t.isInterface() || t.value() == 0 {
+		return nil
+	}
+	sel, _, _ := c.promoted(int(t.value()), name)
+	var res []instruction
+	for _, e := range sel.path {
+		res = append(res, instruction{Code: codeGetAttr, A: reg(c.Globals.Index(e))})
+	}
+	return res
+}
+
+// ifaceDecl is the methods of an interface as declared, see ifaceMethods.
+type ifaceDecl struct {
+	Object
+	methods []string
+	embeds  []int // global index of each embedded interface
+}
+
+// ifaceMethods returns the sorted methods of the interface at global idx,
+// false when they are not known yet.
+func (c *compiler) ifaceMethods(idx int) ([]string, bool) {
+	key := interfaceKey(c.Globals.Key(idx))
+	if !c.Globals.Exists(key) {
+		return nil, false
+	}
+	decl, ok := c.Globals.Get(key).value.(*ifaceDecl)
+	if !ok {
+		return nil, false
+	}
+	names := slices.Clone(decl.methods)
+	for _, e := range decl.embeds {
+		m, ok := c.ifaceMethods(e)
+		if !ok {
+			return nil, false
+		}
+		names = append(names, m...)
+	}
+	slices.Sort(names)
+	return names, true
+}
+
+// implements panics when tok, of a type known at compile time, lacks a
+// method of the interface t. Other values are checked at run time.
+func (c *compiler) implements(tok *token, t Type) {
+	if !t.isInterface() {
+		return
+	}
+	st, ok := c.staticType(tok)
+	if !ok {
+		return
+	}
+	names, ok := c.ifaceMethods(int(t.value()))
+	if !ok {
+		return
+	}
+	for _, name := range names {
+		if has, known, ptr := c.hasMethod(st, name); ptr {
+			panicf("%v does not implement %v (method %v has pointer receiver)", st.str(c.Globals), t.str(c.Globals), name)
+		} else if known && !has {
+			panicf("%v does not implement %v (missing method %v)", st.str(c.Globals), t.str(c.Globals), name)
+		}
+	}
+}
+
+// setVarType records the declared type of the variable at idx, see
+// staticType.
+func (c *compiler) setVarType(idx int, t Type, known bool) {
+	if c.isLocal() {
+		c.Locals.setType(idx, t, known)
+		return
+	}
+	c.Globals.setType(idx, t, known)
+}
+
+// staticType is the type of tok when known at compile time: literals,
+// conversions and variables with a declared or literal type.
+func (c *compiler) staticType(tok *token) (Type, bool) {
+	switch tok.Symbol {
+	case "(name)":
+		if c.Locals.Exists(tok.Text) {
+			return c.Locals.typeOf(c.Locals.Index(tok.Text))
+		}
+		if key := c.expPrefix(tok.Text); c.Globals.Exists(key) {
+			return c.Globals.typeOf(c.Globals.Index(key))
+		}
+	case "(int)", "(char)":
+		return untypedInt, true
+	case "(float)":
+		return TypeFloat64, true
+	case "(string)":
+		return TypeString, true
+	case "[]", "map":
+		return typeFromToken(c, tok), true
+	case "new":
+		return typeFromToken(c, tok.Tokens[0]), true
+	case "address":
+		if t, ok := c.staticType(tok.Tokens[0]); ok && tok.Tokens[0].Symbol == "new" && t.base() == TypeStruct {
+			return t &^ valueMask, true
+		}
+	case "call":
+		const callName = 0
+		if sym := tok.Tokens[callName].Symbol; sym != "(name)" && sym != "." {
+			break
+		}
+		fnc := c.compile(tok.Tokens[callName])
+		if len(fnc) != 1 || fnc[0].Code != codeGlobalGet {
+			break
+		}
+		if typ := c.Globals.Read(int(fnc[0].A)); typ.t == typeType {
+			return typ.typeValue(), true
+		}
+	}
+	return 0, false
+}
+
+// hasMethod reports whether values of type t have the method name, known is
+// false when only the run time can tell. ptr is set when only a pointer to
+// the struct t has it.
+func (c *compiler) hasMethod(t Type, name string) (has, known, ptr bool) {
+	n := t.named()
+	switch t.base() {
+	case TypeStruct:
+		if t.isInterface() || t.value() == 0 {
+			return false, false, false
+		}
+		sel, found, known := c.promoted(int(t.value()), name)
+		if !found {
+			return false, known, false
+		}
+		n = sel.idx
+		if _, ok := c.Globals.method(n, c.Globals.Index(name)); ok && t.isValue() && !sel.ptr && c.Globals.ptrMethod(n, c.Globals.Index(name)) {
+			return false, true, true
+		}
+	case TypeObject, TypeNil:
+		return false, false, false
+	}
+	if n == 0 {
+		return false, true, false
+	}
+	_, ok := c.Globals.method(n, c.Globals.Index(name))
+	return ok, true, false
+}
+
+// structValue is the type of a value of the struct declared at global idx.
+// Interfaces always hold references.
+func (c *compiler) structValue(idx int) Type {
+	if c.Globals.Exists(interfaceKey(c.Globals.Key(idx))) {
+		return structType(Type(idx)) | interfaceMask
+	}
+	return structType(Type(idx)) | valueMask
+}
+
 func typeFromToken(c *compiler, tok *token) Type {
 	switch tok.Symbol {
 	case "[]":
 		return sliceType(typeFromToken(c, tok.Tokens[0]))
 	case "map":
 		return mapType(typeFromToken(c, tok.Tokens[0]), typeFromToken(c, tok.Tokens[1]))
+	case "array":
+		return arrayType(typeFromToken(c, tok.Tokens[1]))
+	case "*":
+		t := typeFromToken(c, tok.Tokens[0])
+		if t.isValue() && t.base() == TypeStruct {
+			t &^= valueMask
+		}
+		return t
 	case "(name)", ".":
 		ref := c.compile(tok)
 		var typ Value
@@ -981,12 +1636,54 @@ func typeFromToken(c *compiler, tok *token) Type {
 			panicf("invalid type: %s", tok.Text)
 		}
 		if typ.t == typeType {
-			return Type(typ.Int())
+			return typ.typeValue()
+		}
+		return c.structValue(int(ref[0].A))
+	case "index":
+		const indexItem, indexKey = 0, 1
+		g := c.generic(tok.Tokens[indexItem])
+		if g == nil {
+			panicf("invalid type: %s", tok.Tokens[indexItem].Text)
 		}
-		return structType(Type(ref[0].A))
+		idx := c.instantiate(g, c.typeArgs(tok.Tokens[indexKey]))
+		if typ := c.Globals.Read(idx); typ.t == typeType {
+			return typ.typeValue()
+		}
+		return c.structValue(idx)
 	case "...":
 		return sliceType(typeFromToken(c, tok.Tokens[0]))
 	default:
 		return convMap[tok.Symbol]
 	}
 }
+
+// rangeExits stops the iterators of the range loops a labeled break,
+// continue or goto leaves. A goto may jump forward to a label not compiled
+// yet, so its stops carry the label and resolveLabels drops those of the
+// loops the label is in.
+func (c *compiler) rangeExits(code code, label reg) []instruction {
+	var res []instruction
+	if code == codeGoto {
+		for i := len(c.ranges) - 1; i >= 0; i-- {
+			res = append(res, instruction{Code: codeRangeStop, A: reg(c.ranges[i]), B: label})
+		}
+		return res
+	}
+	n, ok := c.labelRanges[label]
+	if !ok {
+		return nil
+	}
+	for i := len(c.ranges) - 1; i >= n; i-- {
+		res = append(res, instruction{Code: codeRangeStop, A: reg(c.ranges[i])})
+	}
+	return res
+}
+
+// rangeStops stops the iterators of the range loops a return leaves.
+func (c *compiler) rangeStops() []instruction {
+	var res []instruction
+	for i := len(c.ranges) - 1; i >= 0; i-- {
+		res = append(res, instruction{Code: codeRangeStop, A: reg(c.ranges[i])})
+	}
+	return res
+}
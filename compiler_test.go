@@ -12,10 +12,10 @@ func TestCompile(t *testing.T) {
 		Want string
 	}{
 		{"number", "42", "PUSH 42"},
-		{"add", "2 + 3", "PUSH 2; PUSH 3; ADD"},
-		{"addMul", "2 + 3 * 4", "PUSH 2; PUSH 3; PUSH 4; MUL; ADD"},
-		{"mulAdd", "2 * 3 + 4", "PUSH 2; PUSH 3; MUL; PUSH 4; ADD"},
-		{"parens", "(2+3) * 4", "PUSH 2; PUSH 3; ADD; PUSH 4; MUL"},
+		{"add", "a + 3", "GLOBALGET a; PUSH 3; ADD"},
+		{"addMul", "a + b * c", "GLOBALGET a; GLOBALGET b; GLOBALGET c; MUL; ADD"},
+		{"mulAdd", "a * b + c", "GLOBALGET a; GLOBALGET b; MUL; GLOBALGET c; ADD"},
+		{"parens", "(a+b) * c", "GLOBALGET a; GLOBALGET b; ADD; GLOBALGET c; MUL"},
 		{"twoNumbers", "4 2", "PUSH 4; PUSH 2"},
 		{"assign", "a := 42 a", "PUSH 42; GLOBALSET a; GLOBALGET a"},
 		{"string", `"test"`, `CONST "test"`},
@@ -40,12 +40,12 @@ func TestCompile(t *testing.T) {
 			`PUSH 0; LOCALSET $0; JUMP 6; LOCALGET $0; GLOBALGET builtin.println; CALL 1 0; LOCALGET $0; INCDEC 1; LOCALSET $0; LOCALGET $0; PUSH 10; LT; JUMPTRUE -10`},
 		{"inc", "x := 0; x++", "PUSH 0; GLOBALSET x; GLOBALGET x; INCDEC 1; GLOBALSET x"},
 		{"localDec", "func f(x int) { x-- }", `FUNC 1:0 1 3; TYPE int32; LOCALGET $0; INCDEC -1; LOCALSET $0; GLOBALFUNC f`},
-		{"convert", "float64(42)", "PUSH 42; CONVERT float64"},
+		{"convert", "float64(x)", "GLOBALGET x; CONVERT float64"},
 		{"var", "var x int", "GLOBALZERO x int32"},
 
 		{"sliceInit", "x := []int{1,2,3}", "PUSH 1; PUSH 2; PUSH 3; NEWSLICE int32 3; GLOBALSET x"},
 		{"map", `x := map[string]int{"a":1,"b":2}`, `CONST "a"; PUSH 1; CONST "b"; PUSH 2; NEWMAP string int32 4; GLOBALSET x`},
-		{"range", "func f() { for k,v := range r { println(k,v) } }", `FUNC 0:0 3 7; GLOBALGET r; RANGE $0 4; LOCALGET $1; LOCALGET $2; GLOBALGET builtin.println; CALL 2 0; ITER $0 $1:$2 -5; GLOBALFUNC f`},
+		{"range", "func f() { for k,v := range r { println(k,v) } }", `FUNC 0:0 3 8; GLOBALGET r; RANGE $0 4; LOCALGET $1; LOCALGET $2; GLOBALGET builtin.println; CALL 2 0; ITER $0 $1:$2 -5; RANGESTOP $0; GLOBALFUNC f`},
 		{"get", "x := m[1]", `GLOBALGET m; PUSH 1; GET; GLOBALSET x`},
 		{"getOk", "x, ok := m[1]", `GLOBALGET m; PUSH 1; GETOK; GLOBALSET ok; GLOBALSET x`},
 		{"set", "m[1] = x", `GLOBALGET x; GLOBALGET m; PUSH 1; SET`},
@@ -62,15 +62,19 @@ func TestCompile(t *testing.T) {
 		{"continue", `for { 42 continue 43 }`, `PUSH 42; JUMP 1; PUSH 43; JUMP -4`},
 		{"break", `for { 42 break 43 }`, `PUSH 42; JUMP 2; PUSH 43; JUMP -4`},
 		{"rangeContinue", `func f() { for x := range y { 42 continue 43 } }`,
-			`FUNC 0:0 3 6; GLOBALGET y; RANGE $0 3; PUSH 42; JUMP 1; PUSH 43; ITER $0 $1:$2 -4; GLOBALFUNC f`},
+			`FUNC 0:0 3 7; GLOBALGET y; RANGE $0 3; PUSH 42; JUMP 1; PUSH 43; ITER $0 $1:$2 -4; RANGESTOP $0; GLOBALFUNC f`},
 		{"rangeBreak", `func f() { for x := range y { 42 break 43 } }`,
-			`FUNC 0:0 3 6; GLOBALGET y; RANGE $0 3; PUSH 42; JUMP 2; PUSH 43; ITER $0 $1:$2 -4; GLOBALFUNC f`},
+			`FUNC 0:0 3 7; GLOBALGET y; RANGE $0 3; PUSH 42; JUMP 2; PUSH 43; ITER $0 $1:$2 -4; RANGESTOP $0; GLOBALFUNC f`},
 		{"globalOrder", `func f() { x = 3 } var x = 4`, `FUNC 0:0 0 2; PUSH 3; GLOBALSET x; GLOBALFUNC f; PUSH 4; GLOBALSET x`},
 		{"packageVars", `package main; var x = 5`, `PUSH 5; GLOBALSET main.x`},
 		{"packageFncs", `package main; func f() {}`, `FUNC 0:0 0 0; GLOBALFUNC main.f`},
 		{"importVars", `package main; import "math"; var yum = math.Pi`, `GLOBALGET math.Pi; GLOBALSET main.yum`},
 		{"importFncs", `package main; import ("strings" "math"); var big = math.Max(1.0,2.0);`,
 			`CONST 1.0; CONST 2.0; GLOBALGET math.Max; CALL 2 1; GLOBALSET main.big`},
+		{"methodExpr", `package main; import "time"; var v = time.Duration.Seconds(time.Second);`,
+			`GLOBALGET time.Second; GLOBALGET time.Duration.Seconds; CALL 1 1; GLOBALSET main.v`},
+		{"typeConvertPkg", `package main; import "time"; var v = time.Duration(42);`,
+			`PUSH 42; CONVERT time.Duration; GLOBALSET main.v`},
 		{"importFncsFlat", `package main; import ("math/rand"); var v = rand.Float64();`,
 			`GLOBALGET math/rand.Float64; CALL 0 1; GLOBALSET main.v`},
 		{"varSliceMap", "var a []map[string]int", `GLOBALZERO a []map[string]int32`},
@@ -79,18 +83,24 @@ func TestCompile(t *testing.T) {
 		{"and", `p && q`, `GLOBALGET p; AND 1; GLOBALGET q`},
 		{"or", `p || q`, `GLOBALGET p; OR 1; GLOBALGET q`},
 		{"localSetArg", `func f(x int) { x = 42 }`, `FUNC 1:0 1 2; TYPE int32; PUSH 42; LOCALSET $0; GLOBALFUNC f`},
-		{"not", `!true`, `CONST true; NOT`},
+		{"not", `!x`, `GLOBALGET x; NOT`},
 		{"slice", `a[2:4]`, `GLOBALGET a; PUSH 2; PUSH 4; SLICE`},
 		{"getGet", `a[4][2] = 3`, `PUSH 3; GLOBALGET a; PUSH 4; GET; PUSH 2; SET`},
 		{"typeStruct", `type T struct { X,Y,Z int; Name string }`,
 			`GLOBALREF X; ZERO int32; GLOBALREF Y; ZERO int32; GLOBALREF Z; ZERO int32; GLOBALREF Name; ZERO string; STRUCT 8; GLOBALSTRUCT T`},
+		{"typeStructTags", "type T struct { ID int `json:\"id\"`; Name string }",
+			"GLOBALREF ID; ZERO int32; GLOBALREF Name; ZERO string; STRUCT 4; STRUCTTAG ID `json:\"id\"`; GLOBALSTRUCT T"},
+		{"typeStructEmbed", `type T struct { A; *B; HP int }`,
+			`GLOBALREF A; ZERO A; GLOBALREF B; ZERO B; GLOBALREF HP; ZERO int32; STRUCT 6; STRUCTEMBED A; STRUCTEMBED B; GLOBALSTRUCT T`},
+		{"typeStructPromote", `type A struct { N int }; type B struct { A }; b := &B{}; b.N = b.N + 1`,
+			`GLOBALREF N; ZERO int32; STRUCT 2; GLOBALSTRUCT A; GLOBALREF A; ZERO A; STRUCT 2; STRUCTEMBED A; GLOBALSTRUCT B; NEWSTRUCT B 0; GLOBALSET b; GLOBALGET b; GETATTR A; GETATTR N; PUSH 1; ADD; GLOBALGET b; GETATTR A; SETATTR N`},
 		{"typePackage", `package main; type T struct {}`, `STRUCT 0; GLOBALSTRUCT main.T`},
 		{"newData", `package main; v := &T{ X:1, Y:2, Z:3, Name:"42"}`,
 			`GLOBALREF X; PUSH 1; GLOBALREF Y; PUSH 2; GLOBALREF Z; PUSH 3; GLOBALREF Name; CONST "42"; NEWSTRUCT main.T 8; GLOBALSET main.v`},
 		{"importNewData", `package ext; type T struct { X int } ; package main; import "ext"; v := &ext.T{ X:1 }`,
 			`GLOBALREF X; ZERO int32; STRUCT 2; GLOBALSTRUCT ext.T; GLOBALREF X; PUSH 1; NEWSTRUCT ext.T 2; GLOBALSET main.v`},
-		{"method", `package main; func (t *T) test(a, b int) int { return 40 + 2 }`,
-			`FUNC 3:1 3 4; TYPE main.T; TYPE int32; TYPE int32; TYPE int32; PUSH 40; PUSH 2; ADD; RETURN 1; GLOBALGET main.T; SETMETHOD test`},
+		{"method", `package main; func (t *T) test(a, b int) int { return a + b }`,
+			`FUNC 3:1 3 4; TYPE main.T; TYPE int32; TYPE int32; TYPE int32; LOCALGET $1; LOCALGET $2; ADD; RETURN 1; GLOBALGET main.T; SETMETHOD test`},
 		{"getAttr", `x := obj.attr`, `GLOBALGET obj; GETATTR attr; GLOBALSET x`},
 		{"setAttr", `obj.attr = 42`, `PUSH 42; GLOBALGET obj; SETATTR attr`},
 		{"callAttr", `x := obj.attr()`, `GLOBALGET obj; GETATTR attr; CALL 0 1; GLOBALSET x`},
@@ -100,13 +110,17 @@ func TestCompile(t *testing.T) {
 		{"blankSet", `x, _, y = 1, 2, 3`, `PUSH 1; PUSH 2; PUSH 3; GLOBALSET y; POP; GLOBALSET x`},
 		{"typeInterface", `type T interface { X(k int) int ; Z(k int) ; Y(k string)int }`, `STRUCT 0; GLOBALSTRUCT T; ZERO func; GLOBALGET T; SETMETHOD X; ZERO func; GLOBALGET T; SETMETHOD Z; ZERO func; GLOBALGET T; SETMETHOD Y`},
 		{"typeInterfacePkg", `package main; type T interface { X(k int) int ; Z(k int) ; Y(k string)int }`, `STRUCT 0; GLOBALSTRUCT main.T; ZERO func; GLOBALGET main.T; SETMETHOD X; ZERO func; GLOBALGET main.T; SETMETHOD Z; ZERO func; GLOBALGET main.T; SETMETHOD Y`},
+		{"typeInterfaceEmbed", `type R interface { Read() }; type RC interface { R; Close() }`, `STRUCT 0; GLOBALSTRUCT R; ZERO func; GLOBALGET R; SETMETHOD Read; STRUCT 0; GLOBALSTRUCT RC; GLOBALGET RC; EMBEDMETHODS R; ZERO func; GLOBALGET RC; SETMETHOD Close`},
 		{"globalRange", `for k,v := range m { res += k+v; }`,
-			`GLOBALGET m; RANGE $0 6; GLOBALGET res; LOCALGET $1; LOCALGET $2; ADD; ADD; GLOBALSET res; ITER $0 $1:$2 -7`},
+			`GLOBALGET m; RANGE $0 6; GLOBALGET res; LOCALGET $1; LOCALGET $2; ADD; ADD; GLOBALSET res; ITER $0 $1:$2 -7; RANGESTOP $0`},
 		{"appendEllipsis", `c := append(a, b...)`, `GLOBALGET a; GLOBALGET b; APPEND 2 1; GLOBALSET c`},
 		{"nilEq", "a == nil", `GLOBALGET a; CONST nil; EQ`},
 		{"nilAssign", "a = nil", `CONST nil; GLOBALSET a`},
 		{"makeSlice", "make([]int, 42)", `PUSH 42; MAKE int32`},
 		{"makeMap", "make(map[int]string)", `NEWMAP int32 string 0`},
+		{"makeSliceCap", "make([]int, 0, 8)", `PUSH 0; PUSH 8; MAKE int32`},
+		{"makeMapHint", "make(map[int]string, 8)", `PUSH 8; POP; NEWMAP int32 string 0`},
+		{"minMax", "x := min(a, 1, 2) + max(a, b)", `GLOBALGET a; PUSH 1; PUSH 2; MIN 3; GLOBALGET a; GLOBALGET b; MAX 2; ADD; GLOBALSET x`},
 		{"printlnJustNL", "println()", `GLOBALGET builtin.println; CALL 0 0`},
 		{"forAppend", `for i:=0; i<3; i++ { res = append(res,&T{X:i}) }`,
 			`PUSH 0; LOCALSET $0; JUMP 9; GLOBALGET res; GLOBALREF X; LOCALGET $0; NEWSTRUCT T 2; APPEND 2 0; GLOBALSET res; LOCALGET $0; INCDEC 1; LOCALSET $0; LOCALGET $0; PUSH 3; LT; JUMPTRUE -13`},
@@ -124,7 +138,7 @@ func TestCompile(t *testing.T) {
 		{"initFunc", "func init() { x = 42 }", `FUNC 0:0 0 2; PUSH 42; GLOBALSET x; CALL 0 0`},
 		{"varErr", `var err error`, `GLOBALZERO err struct`},
 		{"stack", `$[0]`, `GLOBALGET $; PUSH 0; GET`},
-		{"intTypes", `type T byte; var t T; t = T(42)`, `GLOBALZERO t uint8; PUSH 42; CONVERT uint8; GLOBALSET t`},
+		{"intTypes", `type T byte; var t T; t = T(42)`, `GLOBALZERO t T; CONST T(42); GLOBALSET t`},
 		{"panic", `panic("hello")`, `CONST "hello"; PANIC`},
 		{"copy", `copy(a,b)`, `GLOBALGET a; GLOBALGET b; COPY`},
 		{"sliceArg", `func f(v []byte) {}`, `FUNC 1:0 1 0; TYPE []uint8; GLOBALFUNC f`},
@@ -138,32 +152,37 @@ func TestCompile(t *testing.T) {
 		{"anyArg", `func f(v any) {}`, `FUNC 1:0 1 0; TYPE any; GLOBALFUNC f`},
 		{"anyRet", `func f() any {}`, `FUNC 0:1 0 0; TYPE any; GLOBALFUNC f`},
 		{"varByte", `var x byte = 42`, `PUSH 42; CAST uint8; GLOBALSET x`},
-		{"intTypesArg", `type T byte; func f(t T) { }`, `FUNC 1:0 1 0; TYPE uint8; GLOBALFUNC f`},
+		{"intTypesArg", `type T byte; func f(t T) { }`, `FUNC 1:0 1 0; TYPE T; GLOBALFUNC f`},
 		{"internalType", `package main; __type(42)`, `PUSH 42; GLOBALGET builtin.__type; CALL 1 0`},
 		{"emptyMap", `x := map[string]int{}`, `NEWMAP string int32 0; GLOBALSET x`},
 		{"callCallNegativeBug", `f(-1).m()`, `PUSH -1; GLOBALGET f; CALL 1 1; GETATTR m; CALL 0 0`},
-		{"iotaCast", `const (val = code(-(iota + 1)))`, `PUSH 0; PUSH 1; ADD; NEGATE; GLOBALGET code; CALL 1 1; GLOBALSET val`},
+		{"iotaCast", `const (val = code(-(iota + 1)))`, `PUSH -1; GLOBALGET code; CALL 1 1; GLOBALSET val`},
 		{"memberInc", `type T struct { N int }; func (t *T) F() { t.N ++ }`, `GLOBALREF N; ZERO int32; STRUCT 2; GLOBALSTRUCT T; FUNC 1:0 1 5; TYPE T; LOCALGET $0; GETATTR N; INCDEC 1; LOCALGET $0; SETATTR N; GLOBALGET T; SETMETHOD F`},
-		{"constRefConst", `const (a = 40; b = a+2 )`, `PUSH 40; GLOBALSET a; GLOBALGET a; PUSH 2; ADD; GLOBALSET b`},
-		{"constWeirdBug", `const a = -42; const b=-a`, `PUSH -42; GLOBALSET a; GLOBALGET a; NEGATE; GLOBALSET b`},
+		{"constRefConst", `const (a = 40; b = a+2 )`, `PUSH 40; GLOBALSET a; PUSH 42; GLOBALSET b`},
+		{"constWeirdBug", `const a = -42; const b=-a`, `PUSH -42; GLOBALSET a; PUSH 42; GLOBALSET b`},
 		{"funcEllipsis", `func f(a int, b ...int) { }`, `FUNC -2:0 2 0; TYPE int32; TYPE []int32; GLOBALFUNC f`},
 		{"funcEllipsisCode", `func f(a ...int) int { return a[0]+a[1] }`, `FUNC -1:1 1 8; TYPE []int32; TYPE int32; LOCALGET $0; PUSH 0; GET; LOCALGET $0; PUSH 1; GET; ADD; RETURN 1; GLOBALFUNC f`},
 		{"callVariadic", `f(a...)`, `GLOBALGET a; GLOBALGET f; CALLVARIADIC 1 0`},
 		{"localStruct", `func f() any { type T struct {}; return &T{} }`, `FUNC 0:1 0 4; TYPE any; STRUCT 0; GLOBALSTRUCT f.T; NEWSTRUCT f.T 0; RETURN 1; GLOBALFUNC f`},
 		{"localStructNotGlobal", `type T struct {}; func f() any { type T struct {}; return &T{} }`, `STRUCT 0; GLOBALSTRUCT T; FUNC 0:1 0 4; TYPE any; STRUCT 0; GLOBALSTRUCT f.T; NEWSTRUCT f.T 0; RETURN 1; GLOBALFUNC f`},
 		{"localAliasNotGlobal", `type T string; func f() any { type T int; var x T = 42; return x }; var x T`,
-			`FUNC 0:1 1 5; TYPE any; PUSH 42; CAST int32; LOCALSET $0; LOCALGET $0; RETURN 1; GLOBALFUNC f; GLOBALZERO x string`},
+			`FUNC 0:1 1 5; TYPE any; PUSH 42; CAST f.T; LOCALSET $0; LOCALGET $0; RETURN 1; GLOBALFUNC f; GLOBALZERO x T`},
 		{"caseBreak", `for { switch true { case true: break } ; v = 42 ; break }`, `CONST true; LOCALSET $0; CONST true; LOCALGET $0; EQ; JUMPFALSE 2; JUMP 1; JUMP 0; PUSH 42; GLOBALSET v; JUMP 1; JUMP -12`},
 		{"caseBreakDefault", `switch true { case true: break; default: v = 0 }`, `CONST true; LOCALSET $0; CONST true; LOCALGET $0; EQ; JUMPFALSE 2; JUMP 3; JUMP 2; PUSH 0; GLOBALSET v`},
-		{"typeAliasSlice", `type Matrix []float64 ; x := Matrix{1,2,3}`, `PUSH 1; PUSH 2; PUSH 3; NEWSLICE float64 3; GLOBALSET x`},
-		{"typeAliasEmptySlice", `type Matrix []float64 ; x := Matrix{}`, `NEWSLICE float64 0; GLOBALSET x`},
-		{"typeAliasMap", `type Matrix map[int]string ; x := Matrix{1:"test"}`, `PUSH 1; CONST "test"; NEWMAP int32 string 2; GLOBALSET x`},
-		{"typeAliasEmptyMap", `type Matrix map[int]string ; x := Matrix{}`, `NEWMAP int32 string 0; GLOBALSET x`},
+		{"labeledBreak", `outer: for { for { break outer } }`, `PASS; JUMP 2; JUMP -2; JUMP -3`},
+		{"goto", `top: 42; goto top`, `PASS; PUSH 42; JUMP -3`},
+		{"typeAliasSlice", `type Matrix []float64 ; x := Matrix{1,2,3}`, `PUSH 1; PUSH 2; PUSH 3; NEWSLICE float64 3; CONVERT Matrix; GLOBALSET x`},
+		{"typeAliasEmptySlice", `type Matrix []float64 ; x := Matrix{}`, `NEWSLICE float64 0; CONVERT Matrix; GLOBALSET x`},
+		{"typeAliasMap", `type Matrix map[int]string ; x := Matrix{1:"test"}`, `PUSH 1; CONST "test"; NEWMAP int32 string 2; CONVERT Matrix; GLOBALSET x`},
+		{"typeAliasEmptyMap", `type Matrix map[int]string ; x := Matrix{}`, `NEWMAP int32 string 0; CONVERT Matrix; GLOBALSET x`},
 		{"typeAliasStruct", `type T struct { K int }; type Matrix T ; x := Matrix{K:42}`, `GLOBALREF K; ZERO int32; STRUCT 2; GLOBALSTRUCT T; GLOBALREF K; PUSH 42; NEWSTRUCT T 2; GLOBALSET x`},
 		{"typeAliasEmptyStruct", `type T struct { K int }; type Matrix T ; x := Matrix{}`, `GLOBALREF K; ZERO int32; STRUCT 2; GLOBALSTRUCT T; NEWSTRUCT T 0; GLOBALSET x`},
-		{"typeAliasMakeSlice", `type Matrix []float64 ; x = make(Matrix, 16)`, `PUSH 16; MAKE float64; GLOBALSET x`},
-		{"typeAliasMakeAlias", `type T struct{}; type B T; type M []B; x = make(M,16)`, `STRUCT 0; GLOBALSTRUCT T; PUSH 16; MAKE T; GLOBALSET x`},
-		{"castToAlias", `type T int; const C = T(42)`, `PUSH 42; CONVERT int32; GLOBALSET C`},
+		{"typeAliasMakeSlice", `type Matrix []float64 ; x = make(Matrix, 16)`, `PUSH 16; MAKE float64; CONVERT Matrix; GLOBALSET x`},
+		{"typeAliasMakeAlias", `type T struct{}; type B T; type M []B; x = make(M,16)`, `STRUCT 0; GLOBALSTRUCT T; PUSH 16; MAKE T; CONVERT M; GLOBALSET x`},
+		{"castToAlias", `type T int; const C = T(42)`, `CONST T(42); GLOBALSET C`},
+		{"constFold", `const big = 1 << 40; x := big >> 30`, `PUSH 1099511627776; GLOBALSET big; PUSH 1024; GLOBALSET x`},
+		{"constFoldFloat", `const f = 1 / 2.0; const s = "a" + "b"`, `CONST 0.5; GLOBALSET f; CONST "ab"; GLOBALSET s`},
+		{"constTyped", `const u uint8 = 254; x := u + 1`, `CONST uint8(254); GLOBALSET u; CONST uint8(255); GLOBALSET x`},
 		{"argNamedType", `type typ struct {}; func f(typ *typ) { }`, `STRUCT 0; GLOBALSTRUCT typ; FUNC 1:0 1 0; TYPE typ; GLOBALFUNC f`},
 		{"fieldNamedType", `type typ struct {}; func f() { var typ typ }`, `STRUCT 0; GLOBALSTRUCT typ; FUNC 0:0 1 1; LOCALZERO $0 typ; GLOBALFUNC f`},
 		{"sliceMapStringStructInit", `type T struct { X int }; type B T; v := []map[string]B{{"x":{X:1}},{"y":{X:2}}}`,
@@ -206,8 +225,37 @@ func TestCompile_error(t *testing.T) {
 		Err  string
 	}{
 		{"undefined", `import "math"; math.Garbage()`, `undefined`},
+		{"interfaceVar", `type I interface { M(); N() }; type T struct{}; func (t *T) M() {}; var i I = &T{}`, `T does not implement I (missing method N)`},
+		{"interfaceReturn", `type I interface { M() }; type T struct{}; func f() I { return &T{} }; f()`, `T does not implement I (missing method M)`},
+		{"interfaceConvert", `type I interface { M() }; type T struct{}; x := I(&T{})`, `T does not implement I (missing method M)`},
+		{"interfaceEmbedded", `type R interface { Read() }; type RC interface { R; Close() }; type T struct{}; func (t *T) Close() {}; var rc RC = &T{}`, `T does not implement RC (missing method Read)`},
+		{"interfacePromoted", `type I interface { M() }; type A struct{}; type B struct{ A }; var i I = &B{}`, `B does not implement I (missing method M)`},
+		{"ambiguousSelector", `type A struct { N int }; type B struct { N int }; type C struct { A; B }; c := &C{}; c.N`, `ambiguous selector N`},
+		{"interfacePointerReceiver", `type I interface { M() }; type P struct{}; func (p *P) M() {}; var i I = P{}`, `P does not implement I (method M has pointer receiver)`},
+		{"interfacePromotedPointer", `type I interface { M() }; type A struct{}; func (a *A) M() {}; type B struct{ A }; var i I = B{}`, `B does not implement I (method M has pointer receiver)`},
+		{"interfaceNamed", `type I interface { M() }; type N int; var i I = N(1)`, `N does not implement I (missing method M)`},
+		{"interfaceVarInt", `type Namer interface { Name() string }; var y Namer = 3`, `number does not implement Namer (missing method Name)`},
 		{"invalidType", `func f() { var T int; var x T }`, `invalid type: T`},
 		{"untypedData", `v := []any{{}}`, `untyped data`},
+		{"cannotInfer", `func Zero[T any]() T { var z T; return z }; x := Zero()`, `in call to Zero, cannot infer T`},
+		{"cannotInferNil", `func F[T any](v T) {}; var a any; F(a); F(nil)`, `in call to F, cannot infer T`},
+		{"typeArgCount", `func F[T, U any]() {}; F[int]()`, `wrong number of type arguments`},
+		{"notGeneric", `type T struct{}; var x T[int]`, `invalid type: T`},
+		{"gotoUndefined", `func f() { goto nope }`, `label nope not defined`},
+		{"breakLabel", `func f() { a: for {}; for { break a } }`, `invalid break label a`},
+		{"newNotStruct", `p := new(int)`, `new: int32 is not a struct type`},
+		{"constOverflow", `const big = 1 << 40; x := big`, `constant 1099511627776 overflows int32`},
+		{"constOverflowVar", `var x int8 = 200`, `constant 200 overflows int8`},
+		{"constOverflowInt64", `var x int64 = 1 << 40`, `constant 1099511627776 overflows int64`},
+		{"constOverflowConstUint64", `const x uint64 = 1 << 40`, `constant 1099511627776 overflows uint64`},
+		{"constOverflowConvertInt64", `x := int64(1 << 40)`, `constant 1099511627776 overflows int64`},
+		{"constOverflowTyped", `const u uint8 = 255; v := u + 1`, `constant 256 overflows uint8`},
+		{"constOverflowIota", `const (a uint8 = iota + 255; b)`, `constant 256 overflows uint8`},
+		{"constOverflowConvert", `x := byte(0x101)`, `constant 257 overflows uint8`},
+		{"constOverflowFloat32", `x := float32(1e39)`, `constant 1e+39 overflows float32`},
+		{"constTruncated", `const f = 1.5; var i int = f`, `constant 1.5 truncated to integer`},
+		{"constDivZero", `x := 1 / 0`, `division by zero`},
+		{"constMismatched", `const u uint8 = 1; const i int8 = 1; x := u + i`, `mismatched types uint8 and int8`},
 	}
 	for _, row := range tests {
 		t.Run(row.Name, func(t *testing.T) {
@@ -0,0 +1,510 @@
+package goatlang
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// Constant expressions are folded at compile time with exact math/big
+// values and Go's rules for untyped constants, so `const big = 1 << 40;
+// x := big >> 30` is 1024 and a constant that does not fit its type is a
+// compile error.
+
+type constKind int
+
+const (
+	constBool constKind = iota
+	constString
+	constInt
+	constRune
+	constFloat
+)
+
+// constant is a folded value: bool, string, *big.Int for ints and runes or
+// *big.Rat for floats, typ is 0 while untyped.
+type constant struct {
+	Object
+	kind constKind
+	typ  Type
+	val  any
+}
+
+// constKey is the hidden global holding the value of the constant key.
+func constKey(key string) string { return key + "{const}" }
+
+// kindOf returns the kind of constants of type t, false when t has none.
+func kindOf(t Type) (constKind, bool) {
+	switch t.underlying() {
+	case TypeBool:
+		return constBool, true
+	case TypeString:
+		return constString, true
+	case TypeInt8, TypeUint8, TypeInt32, TypeUint32:
+		return constInt, true
+	case TypeFloat32, TypeFloat64:
+		return constFloat, true
+	}
+	return 0, false
+}
+
+// defaultType is the type an untyped constant gets in `x := k`.
+func (k *constant) defaultType() Type {
+	switch k.kind {
+	case constBool:
+		return TypeBool
+	case constString:
+		return TypeString
+	case constFloat:
+		return TypeFloat64
+	}
+	return TypeInt32
+}
+
+func (k *constant) isNumeric() bool { return k.kind >= constInt }
+
+// overflows reports whether k is an untyped integer too large for a
+// runtime value.
+func (k *constant) overflows() bool {
+	i, ok := k.val.(*big.Int)
+	return ok && k.typ == 0 && !i.IsInt64()
+}
+
+func (k *constant) int() *big.Int {
+	if r, ok := k.val.(*big.Rat); ok {
+		if !r.IsInt() {
+			panicf("constant %v truncated to integer", k)
+		}
+		return new(big.Int).Set(r.Num())
+	}
+	return k.val.(*big.Int)
+}
+
+func (k *constant) rat() *big.Rat {
+	if i, ok := k.val.(*big.Int); ok {
+		return new(big.Rat).SetInt(i)
+	}
+	return k.val.(*big.Rat)
+}
+
+func (k *constant) float64() float64 {
+	f, _ := k.rat().Float64()
+	return f
+}
+
+func (k *constant) String() string {
+	switch v := k.val.(type) {
+	case string:
+		return strconv.Quote(v)
+	case *big.Rat:
+		return strconv.FormatFloat(k.float64(), 'g', -1, 64)
+	}
+	return fmt.Sprint(k.val)
+}
+
+var intBounds = map[Type][2]int64{
+	TypeInt8:   {math.MinInt8, math.MaxInt8},
+	TypeUint8:  {0, math.MaxUint8},
+	TypeInt32:  {math.MinInt32, math.MaxInt32},
+	TypeUint32: {0, math.MaxUint32},
+}
+
+// convert returns k as a constant of type t, which must have a kind,
+// panicking when k does not fit.
+func (c *compiler) convert(k *constant, t Type) *constant {
+	return c.convertTo(k, t, t.str(c.Globals))
+}
+
+// aliasTypes are the types kept as a smaller one, see convMap.
+var aliasTypes = map[string]bool{"int64": true, "uint64": true, "int16": true, "uint16": true}
+
+// convertType is convert to the type spelled tok, so errors name an aliased
+// int64 rather than the int32 it is kept as.
+func (c *compiler) convertType(k *constant, t Type, tok *token) *constant {
+	if aliasTypes[tok.Symbol] {
+		return c.convertTo(k, t, tok.Symbol)
+	}
+	return c.convert(k, t)
+}
+
+// convertTo is convert with name as the type in errors.
+func (c *compiler) convertTo(k *constant, t Type, name string) *constant {
+	kind, _ := kindOf(t)
+	res := &constant{kind: kind, typ: t}
+	switch {
+	case kind == constString && k.isNumeric():
+		res.val = string(rune(k.int().Int64()))
+	case kind == constInt && k.isNumeric():
+		i, b := k.int(), intBounds[t.underlying()]
+		if !i.IsInt64() || i.Int64() < b[0] || i.Int64() > b[1] {
+			panicf("constant %v overflows %v", k, name)
+		}
+		res.val = i
+	case kind == constFloat && k.isNumeric():
+		f := k.float64()
+		if t.underlying() == TypeFloat32 {
+			f = toFloat32(f)
+		}
+		if math.IsInf(f, 0) {
+			panicf("constant %v overflows %v", k, name)
+		}
+		res.val = new(big.Rat).SetFloat64(f)
+	case kind == k.kind:
+		res.val = k.val
+	default:
+		panicf("cannot convert %v to type %v", k, name)
+	}
+	return res
+}
+
+// constant folds tok, nil when it is not a constant expression.
+func (c *compiler) constant(tok *token) *constant {
+	switch tok.Symbol {
+	case "(int)":
+		i, ok := new(big.Int).SetString(tok.Text, 0)
+		if !ok {
+			panicf("error parsing int: %v", tok.Text)
+		}
+		return &constant{kind: constInt, val: i}
+	case "(char)":
+		return &constant{kind: constRune, val: big.NewInt(int64(tok.Char()))}
+	case "(float)":
+		r, ok := new(big.Rat).SetString(tok.Text)
+		if !ok {
+			panicf("error parsing float: %v", tok.Text)
+		}
+		return &constant{kind: constFloat, val: r}
+	case "(string)":
+		return &constant{kind: constString, val: tok.Unquote()}
+	case "true", "false":
+		return &constant{kind: constBool, val: tok.Symbol == "true"}
+	case "(name)":
+		return c.namedConst(tok.Text)
+	case ".":
+		left, right := tok.Tokens[0], tok.Tokens[1]
+		if pkg, ok := c.Imports[left.Text]; ok && left.Symbol == "(name)" && !c.Locals.Exists(left.Text) {
+			return c.globalConst(pkg + "." + right.Text)
+		}
+	case "call":
+		const callName, callArguments = 0, 1
+		args := tok.Tokens[callArguments].Tokens
+		if t, ok := c.constType(tok.Tokens[callName]); ok && len(args) == 1 {
+			if k := c.constant(args[0]); k != nil {
+				return c.convertType(k, t, tok.Tokens[callName])
+			}
+		}
+	case "negate", "!", "complement":
+		if k := c.constant(tok.Tokens[0]); k != nil {
+			return c.unaryConst(tok.Symbol, k)
+		}
+	case "<", ">", "<=", ">=", "==", "!=", "|", "^", "&", "<<", ">>", "+", "-", "*", "/", "%", "&&", "||":
+		a := c.constant(tok.Tokens[0])
+		if a == nil {
+			return nil
+		}
+		if b := c.constant(tok.Tokens[1]); b != nil {
+			return c.binaryConst(tok.Symbol, a, b)
+		}
+	}
+	return nil
+}
+
+// checkConsts panics when a constant value of the declaration tok does not
+// fit the type of its variable, or its default type.
+func (c *compiler) checkConsts(tok *token) {
+	values, targets := []*token{tok.Tokens[1]}, tok.Tokens[0].Tokens
+	if tok.Tokens[1].Symbol == "," {
+		values = tok.Tokens[1].Tokens
+	}
+	if len(values) != len(targets) {
+		return
+	}
+	for i, target := range targets {
+		k := c.constant(values[i])
+		if k == nil {
+			continue
+		}
+		t := k.typ
+		if t == 0 {
+			t = k.defaultType()
+		}
+		if len(target.Tokens) > 0 {
+			typ := typeFromToken(c, target.Tokens[0])
+			if _, ok := kindOf(typ); ok {
+				c.convertType(k, typ, target.Tokens[0])
+				continue
+			}
+		}
+		c.convert(k, t)
+	}
+}
+
+// unconst forgets the constant declared again as the variable key at idx.
+func (c *compiler) unconst(key string, idx int) {
+	if c.isLocal() {
+		delete(c.consts, idx)
+	} else if c.Globals.Exists(constKey(key)) {
+		c.Globals.Set(constKey(key), Nil())
+	}
+}
+
+// namedConst is the constant name refers to, resolved like "(name)".
+func (c *compiler) namedConst(name string) *constant {
+	if _, ok := c.TypeParams[name]; ok || c.isLocal() && c.Globals.Exists(c.FuncName+"."+name) {
+		return nil
+	}
+	if n, ok := c.Locals.keyToIndex[name]; ok {
+		return c.consts[n]
+	}
+	return c.globalConst(c.expPrefix(name))
+}
+
+func (c *compiler) globalConst(key string) *constant {
+	if !c.Globals.Exists(constKey(key)) {
+		return nil
+	}
+	k, _ := c.Globals.Get(constKey(key)).value.(*constant)
+	return k
+}
+
+// constType is the type tok converts constants to.
+func (c *compiler) constType(tok *token) (Type, bool) {
+	if tok.Symbol == "(name)" && (c.Locals.Exists(tok.Text) || !c.Globals.Exists(c.expPrefix(tok.Text))) {
+		return 0, false
+	}
+	var t Type
+	if tok.Symbol == "(name)" {
+		v := c.Globals.Get(c.expPrefix(tok.Text))
+		if v.t != typeType {
+			return 0, false
+		}
+		t = v.typeValue()
+	} else if t = convMap[tok.Symbol]; t == 0 {
+		return 0, false
+	}
+	_, ok := kindOf(t)
+	return t, ok
+}
+
+func (c *compiler) unaryConst(op string, k *constant) *constant {
+	res := &constant{kind: k.kind, typ: k.typ}
+	switch {
+	case op == "!" && k.kind == constBool:
+		res.val = !k.val.(bool)
+	case op == "negate" && k.kind == constFloat:
+		res.val = new(big.Rat).Neg(k.rat())
+	case op == "negate" && k.isNumeric():
+		res.val = new(big.Int).Neg(k.int())
+	case op == "complement" && k.isNumeric() && k.kind != constFloat:
+		i := new(big.Int).Not(k.int())
+		if b := intBounds[k.typ.underlying()]; b[0] == 0 && b[1] > 0 { // unsigned
+			i.And(i, big.NewInt(b[1]))
+		}
+		res.val = i
+	default:
+		panicf("invalid operation: operator %v not defined on %v", op, k)
+	}
+	if res.typ != 0 {
+		return c.convert(res, res.typ)
+	}
+	return res
+}
+
+func (c *compiler) binaryConst(op string, a, b *constant) *constant {
+	if op == "<<" || op == ">>" {
+		return c.shiftConst(op, a, b)
+	}
+	t := a.typ
+	switch {
+	case t == 0:
+		t = b.typ
+	case b.typ != 0 && b.typ != t:
+		panicf("invalid operation: mismatched types %v and %v", a.typ.str(c.Globals), b.typ.str(c.Globals))
+	}
+	kind := a.kind
+	if b.kind > kind {
+		kind = b.kind
+	}
+	if t != 0 {
+		a, b = c.convert(a, t), c.convert(b, t)
+		kind, _ = kindOf(t)
+	} else if a.isNumeric() != b.isNumeric() || !a.isNumeric() && a.kind != b.kind {
+		panicf("invalid operation: mismatched types %v and %v", a, b)
+	}
+	res := &constant{kind: kind, typ: t}
+	switch kind {
+	case constBool:
+		x, y := a.val.(bool), b.val.(bool)
+		switch op {
+		case "&&":
+			res.val = x && y
+		case "||":
+			res.val = x || y
+		case "==":
+			res.val = x == y
+		case "!=":
+			res.val = x != y
+		}
+	case constString:
+		x, y := a.val.(string), b.val.(string)
+		if op == "+" {
+			res.val = x + y
+		} else if cmp, ok := compareOp(op, compareStrings(x, y)); ok {
+			res.val = cmp
+		}
+	case constFloat:
+		x, y := a.rat(), b.rat()
+		switch op {
+		case "+":
+			res.val = new(big.Rat).Add(x, y)
+		case "-":
+			res.val = new(big.Rat).Sub(x, y)
+		case "*":
+			res.val = new(big.Rat).Mul(x, y)
+		case "/":
+			if y.Sign() == 0 {
+				panicf("invalid operation: division by zero")
+			}
+			res.val = new(big.Rat).Quo(x, y)
+		default:
+			if cmp, ok := compareOp(op, x.Cmp(y)); ok {
+				res.val = cmp
+			}
+		}
+	default:
+		x, y := a.int(), b.int()
+		switch op {
+		case "+":
+			res.val = new(big.Int).Add(x, y)
+		case "-":
+			res.val = new(big.Int).Sub(x, y)
+		case "*":
+			res.val = new(big.Int).Mul(x, y)
+		case "/", "%":
+			if y.Sign() == 0 {
+				panicf("invalid operation: division by zero")
+			}
+			if op == "/" {
+				res.val = new(big.Int).Quo(x, y)
+			} else {
+				res.val = new(big.Int).Rem(x, y)
+			}
+		case "&":
+			res.val = new(big.Int).And(x, y)
+		case "|":
+			res.val = new(big.Int).Or(x, y)
+		case "^":
+			res.val = new(big.Int).Xor(x, y)
+		default:
+			if cmp, ok := compareOp(op, x.Cmp(y)); ok {
+				res.val = cmp
+			}
+		}
+	}
+	switch res.val.(type) {
+	case nil:
+		panicf("invalid operation: operator %v not defined on %v", op, a)
+	case bool:
+		if kind != constBool {
+			return &constant{kind: constBool, val: res.val}
+		}
+	}
+	if t != 0 {
+		return c.convert(res, t)
+	}
+	return res
+}
+
+func (c *compiler) shiftConst(op string, a, b *constant) *constant {
+	if !a.isNumeric() || !b.isNumeric() {
+		panicf("invalid operation: shift of %v by %v", a, b)
+	}
+	n := b.int()
+	if n.Sign() < 0 || n.Cmp(big.NewInt(1023)) > 0 {
+		panicf("invalid shift count %v", b)
+	}
+	res := &constant{kind: a.kind, typ: a.typ}
+	if res.kind == constFloat {
+		res.kind = constInt
+	}
+	if op == "<<" {
+		res.val = new(big.Int).Lsh(a.int(), uint(n.Uint64()))
+	} else {
+		res.val = new(big.Int).Rsh(a.int(), uint(n.Uint64()))
+	}
+	if res.typ != 0 {
+		return c.convert(res, res.typ)
+	}
+	return res
+}
+
+func compareStrings(x, y string) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// compareOp applies the comparison op to the result of a Cmp.
+func compareOp(op string, cmp int) (bool, bool) {
+	switch op {
+	case "==":
+		return cmp == 0, true
+	case "!=":
+		return cmp != 0, true
+	case "<":
+		return cmp < 0, true
+	case "<=":
+		return cmp <= 0, true
+	case ">":
+		return cmp > 0, true
+	case ">=":
+		return cmp >= 0, true
+	}
+	return false, false
+}
+
+// value is k as a runtime value, untyped ints stay untyped.
+func (c *compiler) value(k *constant) Value {
+	t := k.typ
+	if t == 0 {
+		t = k.defaultType()
+	}
+	switch k.kind {
+	case constBool:
+		v := Bool(k.val.(bool))
+		v.t = t
+		return v
+	case constString:
+		return Value{t: t, value: stringT(k.val.(string))}
+	case constFloat:
+		return Value{t: t, num: c.convert(k, t).float64()}
+	}
+	if k.overflows() {
+		panicf("constant %v overflows int", k)
+	} else if k.typ == 0 {
+		return Value{t: untypedInt, num: float64(k.int().Int64())}
+	}
+	return Value{t: t, num: float64(k.int().Int64())}
+}
+
+// pushConst pushes the folded constant k.
+func (c *compiler) pushConst(k *constant) []instruction {
+	v := c.value(k)
+	switch {
+	case v.t == untypedInt:
+		return []instruction{{Code: codePush, A: reg(k.int().Int64())}}
+	case k.typ == 0 && k.kind == constBool:
+		return []instruction{{Code: codeConst, A: reg(c.Globals.Index(k.String()))}}
+	}
+	key := k.String()
+	if k.typ != 0 {
+		key = k.typ.str(c.Globals) + "(" + key + ")"
+	}
+	c.Globals.Set(key, v)
+	return []instruction{{Code: codeConst, A: reg(c.Globals.Index(key))}}
+}
@@ -0,0 +1,227 @@
+package goatlang
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+var (
+	errCoroutineDone   = errors.New("coroutine is done")
+	errCoroutineClosed = errors.New("coroutine closed")
+)
+
+// Coroutine runs a script function a little at a time, see VM.Start.
+type Coroutine struct {
+	// Quantum is the approximate number of instructions run per Resume
+	// before suspending, 0 only suspends at coroutine.Yield.
+	Quantum int
+
+	co *coroutine
+}
+
+// coroutine is the state shared with the goroutine running the function,
+// apart from Coroutine so that one can be collected while it is suspended.
+type coroutine struct {
+	vm      *VM
+	name    string
+	fnc     Value
+	args    []Value
+	quantum int
+	budget  int
+	started bool
+	done    bool
+	closed  bool // set once Close resumes it, see suspend
+	rets    []Value
+	err     error
+	resume  chan bool
+	yield   chan struct{}
+}
+
+// Start creates a suspended coroutine calling the named function. Once
+// resumed it holds a goroutine until it returns or is closed, coroutines
+// dropped before that are closed when garbage collected.
+func (v *VM) Start(name string, params ...Value) *Coroutine {
+	co := &coroutine{
+		name:   name,
+		fnc:    v.globals.Get(name),
+		args:   params,
+		resume: make(chan bool),
+		yield:  make(chan struct{}),
+	}
+	co.vm = &VM{globals: v.globals, stdout: v.stdout, co: co, order: v.order, root: v.root}
+	c := &Coroutine{co: co}
+	runtime.SetFinalizer(c, (*Coroutine).Close)
+	return c
+}
+
+// Resume runs the coroutine until it yields or returns. While suspended rets
+// holds the values passed to coroutine.Yield, once done the function results.
+func (c *Coroutine) Resume() (done bool, rets []Value, err error) {
+	co := c.co
+	if co.done {
+		return true, nil, errCoroutineDone
+	}
+	co.quantum = c.Quantum
+	co.budget = co.quantum
+	if !co.started {
+		co.started = true
+		go co.run()
+	} else {
+		co.resume <- false
+	}
+	<-co.yield
+	return co.done, co.rets, co.err
+}
+
+// Close abandons a suspended coroutine, ending its goroutine.
+func (c *Coroutine) Close() {
+	co := c.co
+	if co.started && !co.done {
+		co.resume <- true
+		<-co.yield
+	}
+	co.done = true
+}
+
+func (c *coroutine) run() {
+	f, ok := c.fnc.value.(*funcT)
+	if !ok {
+		c.done, c.err = true, fmt.Errorf("not a function: %v", c.name)
+		c.yield <- struct{}{}
+		return
+	}
+	rets, err := c.vm.Func(c.fnc, f.Rets, c.args...)
+	c.done, c.rets, c.err = true, rets, err
+	c.yield <- struct{}{}
+}
+
+// suspend hands rets to Resume and waits to be resumed. A closed coroutine
+// panics, again on every later tick or yield should a host callback swallow
+// the error, so that it returns instead of waiting forever.
+func (c *coroutine) suspend(rets []Value) {
+	if c.closed {
+		panic(errCoroutineClosed)
+	}
+	c.rets = rets
+	c.yield <- struct{}{}
+	if <-c.resume {
+		c.closed = true
+		panic(errCoroutineClosed)
+	}
+	c.budget = c.quantum
+}
+
+func (c *coroutine) tick(n int) {
+	if c.closed {
+		panic(errCoroutineClosed)
+	}
+	if c.quantum == 0 {
+		return
+	}
+	c.budget -= n
+	if c.budget <= 0 {
+		c.suspend(nil)
+	}
+}
+
+// pullT runs a range-over-func iterator on its own goroutine, handing each
+// yielded pair back to the ranging VM. Only one of them runs at a time, so
+// the iterator may suspend the coroutine of the ranging VM too.
+type pullT struct {
+	items   chan [2]Value
+	resume  chan bool
+	started bool
+	done    bool
+	err     error
+}
+
+// pull returns a range iterator over the func f. yield returns false once
+// the loop stops early, see codeRangeStop.
+func (v *VM) pull(f Value) Value {
+	p := &pullT{items: make(chan [2]Value), resume: make(chan bool)}
+	yield := NewFunc(1, 1, func(vm *VM, args []Value, vargs ...Value) []Value {
+		if p.done {
+			return []Value{Bool(false)}
+		}
+		item := [2]Value{Nil(), Nil()}
+		copy(item[:], vargs)
+		p.items <- item
+		return []Value{Bool(<-p.resume)}
+	})
+	next := func() (Value, Value, bool) {
+		if p.done {
+			return Nil(), Nil(), false
+		}
+		if !p.started {
+			p.started = true
+			v.iters = append(v.iters, p)
+			go func() {
+				vm := &VM{globals: v.globals, stdout: v.stdout, co: v.co, order: v.order, root: v.root}
+				_, p.err = vm.Func(f, 0, yield)
+				close(p.items)
+			}()
+		} else {
+			p.resume <- true
+		}
+		item, ok := <-p.items
+		if !ok {
+			p.done = true
+			v.dropIter(p)
+			if p.err != nil {
+				panic(p.err)
+			}
+			return Nil(), Nil(), false
+		}
+		return item[0], item[1], true
+	}
+	stop := func() {
+		v.dropIter(p)
+		if err := p.stop(); err != nil {
+			panic(err)
+		}
+	}
+	return Value{t: typeNext, value: &nextT{next: next, stop: stop}}
+}
+
+// stop makes yield return false and waits for the iterator to return.
+func (p *pullT) stop() error {
+	if !p.started || p.done {
+		p.done = true
+		return nil
+	}
+	p.done = true
+	p.resume <- false
+	for range p.items {
+	}
+	return p.err
+}
+
+func (v *VM) dropIter(p *pullT) {
+	for i := len(v.iters) - 1; i >= 0; i-- {
+		if v.iters[i] == p {
+			v.iters = append(v.iters[:i], v.iters[i+1:]...)
+			return
+		}
+	}
+}
+
+// stopIters stops the iterators of the range loops a panic unwinds, so their
+// goroutines do not wait on yield forever.
+func (v *VM) stopIters() {
+	for len(v.iters) > 0 {
+		p := v.iters[len(v.iters)-1]
+		v.iters = v.iters[:len(v.iters)-1]
+		p.stop()
+	}
+}
+
+func loadCoroutine(g *lookup) {
+	g.Set("coroutine.Yield", NewFunc(1, 0, func(v *VM, args []Value, vargs ...Value) []Value {
+		if v.co == nil {
+			panic("coroutine.Yield: not in a coroutine")
+		}
+		v.co.suspend(append([]Value(nil), vargs...))
+		return nil
+	}))
+}
@@ -0,0 +1,268 @@
+package goatlang
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testCoroutineVM(t *testing.T, src string) *VM {
+	vm := New()
+	if err := vm.Load(mapFS{"main/main.go": src}, "main"); err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	return vm
+}
+
+func testResume(t *testing.T, co *Coroutine) string {
+	done, rets, err := co.Resume()
+	if err != nil {
+		return fmt.Sprint(done, " ", err)
+	}
+	return fmt.Sprint(done, " ", rets)
+}
+
+func TestCoroutine(t *testing.T) {
+	vm := testCoroutineVM(t, `package main
+		import "coroutine"
+		func enemyAI(n int) string {
+			for i := 0; i < n; i++ {
+				coroutine.Yield(i, i*i)
+			}
+			return "done"
+		}`)
+	a, b := vm.Start("main.enemyAI", Int(2)), vm.Start("main.enemyAI", Int(1))
+	assert(t, "a1", testResume(t, a), "false [0 0]")
+	assert(t, "b1", testResume(t, b), "false [0 0]")
+	assert(t, "a2", testResume(t, a), "false [1 1]")
+	assert(t, "b2", testResume(t, b), "true [done]")
+	assert(t, "a3", testResume(t, a), "true [done]")
+	assert(t, "a4", testResume(t, a), "true coroutine is done")
+}
+
+func TestCoroutine_Quantum(t *testing.T) {
+	vm := testCoroutineVM(t, `package main
+		var n int
+		func count() int {
+			for i := 0; i < 1000; i++ {
+				n++
+			}
+			return n
+		}`)
+	co := vm.Start("main.count")
+	co.Quantum = 100
+	resumes := 0
+	for {
+		done, rets, err := co.Resume()
+		if err != nil {
+			t.Fatalf("Resume error: %v", err)
+		}
+		resumes++
+		if done {
+			assert(t, "rets", fmt.Sprint(rets), "[1000]")
+			break
+		}
+		if n := vm.Get("main.n").Int(); n == 0 || n >= 1000 {
+			t.Fatalf("n got %v", n)
+		}
+	}
+	if resumes < 10 {
+		t.Fatalf("resumes got %v", resumes)
+	}
+}
+
+func TestCoroutine_callback(t *testing.T) {
+	vm := testCoroutineVM(t, `package main
+		import (
+			"coroutine"
+			"golang.org/x/exp/slices"
+		)
+		func sorted() []int {
+			s := []int{3, 1, 2}
+			slices.SortFunc(s, func(a, b int) bool { coroutine.Yield(a, b); return a < b })
+			return s
+		}`)
+	co := vm.Start("main.sorted")
+	var res string
+	for {
+		done, rets, err := co.Resume()
+		if err != nil {
+			t.Fatalf("Resume error: %v", err)
+		}
+		if done {
+			res = fmt.Sprint(rets)
+			break
+		}
+	}
+	assert(t, "res", res, "[[1 2 3]]")
+}
+
+func TestCoroutine_Close(t *testing.T) {
+	vm := testCoroutineVM(t, `package main
+		import "coroutine"
+		func loop() {
+			for {
+				coroutine.Yield()
+			}
+		}`)
+	co := vm.Start("main.loop")
+	assert(t, "r1", testResume(t, co), "false []")
+	co.Close()
+	assert(t, "r2", testResume(t, co), "true coroutine is done")
+}
+
+func TestCoroutine_rangeFunc(t *testing.T) {
+	vm := testCoroutineVM(t, `package main
+		import "coroutine"
+		func seq(yield func(int) bool) {
+			for i := 0; i < 3; i++ {
+				coroutine.Yield(i)
+				if !yield(i) {
+					return
+				}
+			}
+		}
+		func sum() int {
+			n := 0
+			for v := range seq {
+				n += v
+			}
+			return n
+		}`)
+	co := vm.Start("main.sum")
+	assert(t, "r1", testResume(t, co), "false [0]")
+	assert(t, "r2", testResume(t, co), "false [1]")
+	assert(t, "r3", testResume(t, co), "false [2]")
+	assert(t, "r4", testResume(t, co), "true [3]")
+}
+
+func TestCoroutine_swallowClose(t *testing.T) {
+	vm := testCoroutineVM(t, `package main
+		import "coroutine"
+		func step() { coroutine.Yield() }
+		func loop() {
+			for {
+				swallow(step)
+			}
+		}`)
+	vm.Set("main.swallow", NewFunc(1, 0, func(v *VM, args []Value) { v.Func(args[0], 0) }))
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		co := vm.Start("main.loop")
+		assert(t, "r1", testResume(t, co), "false []")
+		co.Close()
+		assert(t, "r2", testResume(t, co), "true coroutine is done")
+	}
+	assertGoroutines(t, before)
+}
+
+func TestCoroutine_leak(t *testing.T) {
+	vm := testCoroutineVM(t, `package main
+		import "coroutine"
+		func seq(yield func(int) bool) { for i := 0; i < 100; i++ { if !yield(i) { return } } }
+		func loop() {
+			for v := range seq {
+				coroutine.Yield(v)
+			}
+		}
+		func inner(yield func(int) bool) { for { coroutine.Yield(); if !yield(0) { return } } }
+		func loopInner() {
+			for range inner {
+			}
+		}`)
+	t.Run("closeInner", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+		for i := 0; i < 100; i++ {
+			co := vm.Start("main.loopInner")
+			co.Resume()
+			co.Close()
+		}
+		assertGoroutines(t, before)
+	})
+	t.Run("close", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+		for i := 0; i < 100; i++ {
+			co := vm.Start("main.loop")
+			co.Resume()
+			co.Close()
+		}
+		assertGoroutines(t, before)
+	})
+	t.Run("drop", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+		for i := 0; i < 100; i++ {
+			vm.Start("main.loop").Resume()
+		}
+		for i := 0; i < 10 && runtime.NumGoroutine() > before; i++ {
+			runtime.GC()
+			time.Sleep(time.Millisecond)
+		}
+		assertGoroutines(t, before)
+	})
+}
+
+func TestCoroutine_error(t *testing.T) {
+	tests := []struct {
+		Name string
+		Src  string
+		Fnc  string
+		Err  string
+	}{
+		{"panic", `package main; func f() { panic("boom") }`, "main.f", "boom"},
+		{"notFunc", `package main; var f int`, "main.f", "main.f"},
+		{"notCoroutine", `package main; import "coroutine"; func f() { coroutine.Yield() }`, "", "not in a coroutine"},
+	}
+	for _, row := range tests {
+		t.Run(row.Name, func(t *testing.T) {
+			vm := testCoroutineVM(t, row.Src)
+			var err error
+			if row.Fnc == "" {
+				_, err = vm.Call("main.f", 0)
+			} else {
+				_, _, err = vm.Start(row.Fnc).Resume()
+			}
+			if err == nil || !strings.Contains(err.Error(), row.Err) {
+				t.Fatalf("error got %v want %v", err, row.Err)
+			}
+		})
+	}
+}
+
+// assertGoroutines waits a little for goroutines to exit, then checks no
+// more than want are left.
+func assertGoroutines(t *testing.T, want int) {
+	t.Helper()
+	n := runtime.NumGoroutine()
+	for i := 0; i < 100 && n > want; i++ {
+		time.Sleep(time.Millisecond)
+		n = runtime.NumGoroutine()
+	}
+	if n > want {
+		t.Fatalf("goroutines got %v want %v", n, want)
+	}
+}
+
+func TestRangeFunc_leak(t *testing.T) {
+	const seq = `func seq(yield func(int) bool) { for i := 0; i < 100; i++ { if !yield(i) { return } } }; `
+	tests := []struct {
+		Name string
+		In   string
+	}{
+		{"breakOuter", seq + `outer: for { for v := range seq { if v == 2 { break outer } } }`},
+		{"continueOuter", seq + `outer: for _, a := range []int{1, 2} { for v := range seq { if v == a { continue outer } } }`},
+		{"goto", seq + `func f() { for v := range seq { if v == 2 { goto out } }; out: }; f()`},
+		{"panic", seq + `for v := range seq { if v == 2 { panic("boom") } }`},
+		{"panicNested", seq + `func f() { for v := range seq { for w := range seq { if v+w == 3 { panic("boom") } } } }; f()`},
+	}
+	for _, row := range tests {
+		t.Run(row.Name, func(t *testing.T) {
+			before := runtime.NumGoroutine()
+			for i := 0; i < 100; i++ {
+				New().Eval(mapFS{}, row.Name, row.In)
+			}
+			assertGoroutines(t, before)
+		})
+	}
+}
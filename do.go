@@ -2,6 +2,7 @@ package goatlang
 
 import (
 	"fmt"
+	"math"
 )
 
 func (v *VM) exec() {
@@ -11,7 +12,7 @@ func (v *VM) exec() {
 	for v.frame.N = 0; v.frame.N < l; v.frame.N++ {
 		switch codes[v.frame.N].Code {
 		case codePush, codeGlobalRef:
-			v.stack = append(v.stack, newUntypedInt(int(codes[v.frame.N].A)))
+			v.stack = append(v.stack, Value{t: untypedInt, num: float64(codes[v.frame.N].A)})
 
 		case codePop:
 			v.stack = v.stack[:len(v.stack)-1]
@@ -81,11 +82,11 @@ func (v *VM) exec() {
 		case codeIncDec:
 			i := &codes[v.frame.N]
 			a := v.stack[len(v.stack)-1]
-			v.stack[len(v.stack)-1] = a.opAdd(Int(int(i.A)))
+			v.stack[len(v.stack)-1] = a.opAdd(newUntypedInt(int(i.A)))
 
 		case codeLocalIncDec:
 			i := &codes[v.frame.N]
-			v.stack[baseN+int(i.A)] = v.stack[baseN+int(i.A)].opAdd(Int(int(i.B)))
+			v.stack[baseN+int(i.A)] = v.stack[baseN+int(i.A)].opAdd(newUntypedInt(int(i.B)))
 
 		case codeConvert:
 			i := &codes[v.frame.N]
@@ -94,8 +95,22 @@ func (v *VM) exec() {
 		case codeCast:
 			i := &codes[v.frame.N]
 			a := v.stack[len(v.stack)-1]
+			if t := Type(i.A); t.isInterface() {
+				v.implements(a, t)
+			}
 			v.stack[len(v.stack)-1] = a.assign(Type(i.A))
 
+		case codeAssert, codeAssertOk:
+			i := &codes[v.frame.N]
+			r, ok, err := v.assert(v.stack[len(v.stack)-1], Type(i.A))
+			if i.Code == codeAssert && !ok {
+				panic(err)
+			}
+			v.stack[len(v.stack)-1] = r
+			if i.Code == codeAssertOk {
+				v.stack = append(v.stack, Bool(ok))
+			}
+
 		case codeNegate:
 			v.stack[len(v.stack)-1] = v.stack[len(v.stack)-1].opMul(newUntypedInt(-1))
 		case codeBitComplement:
@@ -108,7 +123,7 @@ func (v *VM) exec() {
 
 		case codeZero:
 			i := &codes[v.frame.N]
-			v.stack = append(v.stack, newZero(Type(i.A)))
+			v.stack = append(v.stack, v.zero(Type(i.A)))
 
 		case codeAnd:
 			i := &codes[v.frame.N]
@@ -142,7 +157,7 @@ func (v *VM) exec() {
 			i := &codes[v.frame.N]
 			a := v.globals.Read(int(i.A))
 			if a.IsNil() {
-				v.globals.Assign(int(i.A), newZero(Type(i.B)))
+				v.globals.Assign(int(i.A), v.zero(Type(i.B)))
 			}
 
 		case codeGlobalFunc:
@@ -170,6 +185,8 @@ func (v *VM) exec() {
 			tokens := codes[v.frame.N+1 : v.frame.N+1+int(nargs+rets+jump)]
 			v.frame.N += int(nargs + rets + jump)
 			f := newFunc(int(args), int(rets), mkFunc(int(nargs), int(rets), int(slots), tokens))
+			f.getFunc().sig = tokens[:nargs+rets]
+			f.getFunc().vm = v.root
 			if args < 0 {
 				f.getFunc().VariadicType = Type(tokens[nargs-1].A)
 			}
@@ -197,7 +214,7 @@ func (v *VM) exec() {
 
 		case codeLocalZero:
 			i := &codes[v.frame.N]
-			v.stack[baseN+int(i.A)] = newZero(Type(i.B))
+			v.stack[baseN+int(i.A)] = v.zero(Type(i.B))
 
 		case codeReturn:
 			return
@@ -218,9 +235,15 @@ func (v *VM) exec() {
 				break
 			}
 			i := &codes[v.frame.N]
+			if v.co != nil && i.A < 0 {
+				v.co.tick(int(-i.A))
+			}
 			v.frame.N += int(i.A)
 		case codeJump:
 			i := &codes[v.frame.N]
+			if v.co != nil && i.A < 0 {
+				v.co.tick(int(-i.A))
+			}
 			v.frame.N += int(i.A)
 
 		case codeLt:
@@ -328,12 +351,16 @@ func (v *VM) exec() {
 
 		case codeMake:
 			i := &codes[v.frame.N]
-			l := v.stack[len(v.stack)-1].Int()
-			s := make([]Value, l)
+			l, c := v.stack[len(v.stack)-int(i.B)].Int(), v.stack[len(v.stack)-1].Int()
+			v.stack = v.stack[:len(v.stack)-int(i.B)+1]
+			if l < 0 || c < l {
+				panicf("makeslice: len out of range")
+			}
+			s := make([]Value, l, c)
 			for j := 0; j < l; j++ {
-				s[j] = newZero(Type(i.A))
+				s[j] = v.zero(Type(i.A))
 			}
-			value := NewSlice(Type(i.A), s)
+			value := makeSlice(Type(i.A), s, c)
 			v.stack[len(v.stack)-1] = value
 
 		case codeNewMap:
@@ -346,13 +373,23 @@ func (v *VM) exec() {
 			i := &codes[v.frame.N]
 			a := v.stack[len(v.stack)-1]
 			v.stack = v.stack[:len(v.stack)-1]
-			if a.value != nil {
-				v.stack[baseN+int(i.A)] = newNext(a.Range())
-			} else {
+			v.stack[baseN+int(i.A)].stop()
+			switch {
+			case a.t.base() == TypeFunc && a.value != nil:
+				v.stack[baseN+int(i.A)] = v.pull(a)
+			case a.value != nil:
+				v.stack[baseN+int(i.A)] = newNext(v.mapRange(a))
+			case a.t&isNumericMask != 0:
+				v.stack[baseN+int(i.A)] = newNext(intRange(a))
+			default:
 				v.stack[baseN+int(i.A)] = newNext(nilRange())
 			}
 			v.frame.N += int(i.B)
 
+		case codeRangeStop:
+			i := &codes[v.frame.N]
+			v.stack[baseN+int(i.A)].stop()
+
 		case codeIter:
 			i := &codes[v.frame.N]
 			key, value, ok := v.stack[baseN+int(i.A)].next()
@@ -362,21 +399,77 @@ func (v *VM) exec() {
 			v.frame.N += int(i.C)
 			b1, b2 := splitParams(i.B)
 			v.stack[baseN+int(b1)] = key
+			if value.t.isValue() {
+				value = value.copy(value.t)
+			}
 			v.stack[baseN+int(b2)] = value
+			if v.co != nil {
+				v.co.tick(int(-i.C))
+			}
 
 		case codeStruct:
 			i := &codes[v.frame.N]
 			lookup := map[string]int{}
 			data := newIntMap(int(i.A) / 2)
 			methods := newIntMap(0)
-			s := newStruct(0, lookup, nil, data, &methods)
+			s := newStruct(0, lookup, nil, map[string]string{}, data, &methods, nil)
 			for n := 0; n < int(i.A); n += 2 {
-				k := v.stack[len(v.stack)-int(i.A)+n].Int()
-				s.addField(v.globals.Key(k), k, v.stack[len(v.stack)-int(i.A)+n+1])
+				k, f := v.stack[len(v.stack)-int(i.A)+n].Int(), v.stack[len(v.stack)-int(i.A)+n+1]
+				s.addField(v.globals.Key(k), k, f)
+				if f.t.isValue() && f.t.base() == TypeStruct {
+					st := s.value.(*structT)
+					st.Values = append(st.Values, k)
+				}
 			}
 			v.stack = v.stack[:len(v.stack)-int(i.A)]
 			v.stack = append(v.stack, s)
 
+		case codeStructTag:
+			i := &codes[v.frame.N]
+			s := v.stack[len(v.stack)-1].value.(*structT)
+			s.Tags[v.globals.Key(int(i.A))] = v.globals.Read(int(i.B)).String()
+
+		case codeAddress:
+			a := &v.stack[len(v.stack)-1]
+			if a.t.isValue() {
+				a.t &^= valueMask
+			}
+
+		case codeDeref:
+			a := &v.stack[len(v.stack)-1]
+			if a.t.base() == TypeStruct && a.value != nil {
+				a.t |= valueMask
+			}
+
+		case codeSetDeref:
+			val, ptr := v.stack[len(v.stack)-2], v.stack[len(v.stack)-1]
+			v.stack = v.stack[:len(v.stack)-2]
+			ptr.setDeref(val)
+
+		case codeArray:
+			i := &codes[v.frame.N]
+			t, k := Type(i.A), int(i.B)
+			zero, n := v.stack[len(v.stack)-2], v.stack[len(v.stack)-1].Int()
+			if k > n {
+				panicIndex(n, n)
+			}
+			items := v.stack[len(v.stack)-2-k : len(v.stack)-2]
+			s := make([]Value, n)
+			for j := range s {
+				if j < k {
+					s[j] = items[j].assign(t.value())
+				} else {
+					s[j] = zero.assign(t.value())
+				}
+			}
+			v.stack = v.stack[:len(v.stack)-2-k]
+			v.stack = append(v.stack, Value{t: t, value: &sliceT{valueType: t.value(), data: s}})
+
+		case codeStructEmbed:
+			i := &codes[v.frame.N]
+			s := v.stack[len(v.stack)-1].value.(*structT)
+			s.Embeds = append(s.Embeds, int(i.A))
+
 		case codeGlobalStruct:
 			i := &codes[v.frame.N]
 			prev := v.globals.Read(int(i.A))
@@ -392,7 +485,11 @@ func (v *VM) exec() {
 		case codeNewStruct:
 			i := &codes[v.frame.N]
 			parent := v.globals.Read(int(i.A))
+			v.allocValues(parent)
 			s := newStructByIndex(parent, v.stack[len(v.stack)-int(i.B):])
+			if i.C != 0 {
+				s.t |= valueMask
+			}
 			v.stack = v.stack[:len(v.stack)-int(i.B)]
 			v.stack = append(v.stack, s)
 
@@ -403,6 +500,16 @@ func (v *VM) exec() {
 		// 	v.stack = v.stack[:len(v.stack)-int(i.B)]
 		// 	v.stack = append(v.stack, s)
 
+		case codeEmbedMethods:
+			i := &codes[v.frame.N]
+			s := v.stack[len(v.stack)-1]
+			v.stack = v.stack[:len(v.stack)-1]
+			if e, ok := v.globals.Read(int(i.A)).value.(*structT); ok {
+				for name, k := range e.Lookup {
+					s.addMethod(name, k, newZero(TypeFunc))
+				}
+			}
+
 		case codeSetMethod:
 			i := &codes[v.frame.N]
 			k := int(i.A)
@@ -470,12 +577,44 @@ func (v *VM) exec() {
 			a := v.stack[len(v.stack)-2]
 			b := v.stack[len(v.stack)-1]
 			v.stack = v.stack[:len(v.stack)-2]
-			if b.t.base() == TypeSlice {
+			if s, ok := a.value.(compactSlice); ok {
+				s.copyFrom(b)
+			} else if b.t.base() == TypeSlice {
 				copy(a.data(), b.data())
 			} else {
 				copy(a.data(), b.convert(TypeSlice).data())
 			}
 
+		case codeMin, codeMax:
+			i := &codes[v.frame.N]
+			args := v.stack[len(v.stack)-int(i.A):]
+			v.stack = v.stack[:len(v.stack)-int(i.A)+1]
+			r := args[0]
+			for _, a := range args[1:] {
+				t := mixType(r.t, a.t)
+				if math.IsNaN(a.num) || !math.IsNaN(r.num) && a.opLt(r).Bool() == (i.Code == codeMin) { // NaN wins, like Go
+					r = a
+				}
+				r.t = t
+			}
+			v.stack[len(v.stack)-1] = r
+
+		case codeClear:
+			a := v.stack[len(v.stack)-1]
+			v.stack = v.stack[:len(v.stack)-1]
+			a.clear(v)
+
+		case codeCap:
+			r := v.stack[len(v.stack)-1]
+			switch s := r.value.(type) {
+			case *sliceT:
+				v.stack[len(v.stack)-1] = Int(cap(s.data))
+			case compactSlice:
+				v.stack[len(v.stack)-1] = Int(s.capacity())
+			default:
+				v.stack[len(v.stack)-1] = Int(0)
+			}
+
 		case codePass:
 
 		default: // TODO: comment out
@@ -0,0 +1,370 @@
+package goatlang
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+// generic is a func or type with type parameters, instances are compiled
+// on demand with the parameters bound to concrete types.
+type generic struct {
+	Object
+	name    string
+	params  []string
+	decl    *token
+	methods []*token
+	ctx     compiler
+	done    map[string]bool
+	args    map[Type][]Type   // instance type -> type arguments
+	funcs   map[string]*funcT // instance key -> func
+}
+
+// genericKey is the hidden global holding the generic for key.
+func genericKey(key string) string { return key + "[]" }
+
+func typeParams(tok *token) []string {
+	var res []string
+	for _, t := range tok.Tokens {
+		res = append(res, t.Text)
+	}
+	return res
+}
+
+// declare registers the generic funcs, types and methods of a package so
+// they can be instantiated before their declaration is compiled, and the
+// methods of its types for compile time interface checks.
+func (c *compiler) declare(tokens []*token) {
+	types := map[string]*token{}
+	for _, tok := range tokens {
+		if tok.Symbol == "type" && len(tok.Tokens) == 2 {
+			types[tok.Tokens[0].Text] = tok.Tokens[1]
+		}
+	}
+	for _, tok := range tokens {
+		switch {
+		case tok.Symbol == "package":
+			c.compile(tok)
+		case tok.Symbol == "type" && len(tok.Tokens) > 2, tok.Symbol == "function" && len(tok.Tokens) > 2:
+			key := c.expPrefix(tok.Tokens[0].Text)
+			g := &generic{
+				name:   tok.Tokens[0].Text,
+				params: typeParams(tok.Tokens[2]),
+				decl:   tok,
+				ctx:    compiler{Globals: c.Globals, PackageName: c.PackageName, ExportName: c.ExportName, Imports: c.Imports, Optimize: c.Optimize},
+				done:   map[string]bool{},
+				args:   map[Type][]Type{},
+				funcs:  map[string]*funcT{},
+			}
+			c.Globals.Set(genericKey(key), Wrap(g))
+			c.Globals.Index(key)
+			if tok.Symbol == "function" {
+				c.Globals.Set(key, g.dispatch())
+			}
+		case tok.Symbol == "type" && tok.Tokens[1].Symbol == "interface":
+			c.Globals.Set(interfaceKey(c.expPrefix(tok.Tokens[0].Text)), Bool(true))
+		case tok.Symbol == "method" && isGenericMethod(tok):
+			key := genericKey(c.expPrefix(tok.Tokens[0].Text))
+			if !c.Globals.Exists(key) {
+				panicf("undefined: %v", tok.Tokens[0].Text)
+			}
+			g := c.Globals.Get(key).value.(*generic)
+			g.methods = append(g.methods, tok)
+		case tok.Symbol == "method": // see compiler.hasMethod
+			typ, ok := types[tok.Tokens[0].Text]
+			if !ok {
+				break
+			}
+			key, name := c.expPrefix(tok.Tokens[0].Text), tok.Tokens[1].Text
+			index := -1
+			if typ.Symbol != "struct" {
+				index = c.Globals.Index(key + "." + name)
+			}
+			c.Globals.setMethod(c.Globals.Index(key), c.Globals.Index(name), index, ptrReceiver(tok))
+		case tok.Symbol == "type" && tok.Tokens[1].Symbol == "struct":
+			c.declareStruct(c.expPrefix(tok.Tokens[0].Text), tok.Tokens[1])
+		}
+	}
+}
+
+// ptrReceiver reports whether the method tok has a pointer receiver.
+func ptrReceiver(tok *token) bool {
+	const methodFunc = 2
+	return tok.Tokens[methodFunc].Tokens[0].Tokens[0].Tokens[0].Symbol == "*"
+}
+
+func isGenericMethod(tok *token) bool {
+	const methodFunc = 2
+	return elemType(tok.Tokens[methodFunc].Tokens[0].Tokens[0].Tokens[0]).Symbol == "index"
+}
+
+// generic returns the generic tok refers to, or nil.
+func (c *compiler) generic(tok *token) *generic {
+	if tok.Symbol != "(name)" && tok.Symbol != "." || tok.Symbol == "(name)" && c.Locals.Exists(tok.Text) {
+		return nil
+	}
+	ref := c.compile(tok)
+	if len(ref) != 1 || ref[0].Code != codeGlobalGet {
+		return nil
+	}
+	key := genericKey(c.Globals.Key(int(ref[0].A)))
+	if !c.Globals.Exists(key) {
+		return nil
+	}
+	g, _ := c.Globals.Get(key).value.(*generic)
+	return g
+}
+
+func (c *compiler) typeArgs(tok *token) []Type {
+	var res []Type
+	for _, t := range plural(tok).Tokens {
+		res = append(res, typeFromToken(c, t))
+	}
+	return res
+}
+
+// instantiate compiles g for args once, queueing the code in c.Instances,
+// and returns the global index of the instance.
+func (c *compiler) instantiate(g *generic, args []Type) int {
+	if len(args) != len(g.params) {
+		panicf("wrong number of type arguments for %v: got %d want %d", g.name, len(args), len(g.params))
+	}
+	var names []string
+	for _, t := range args {
+		names = append(names, t.str(c.Globals))
+	}
+	name := g.name + "[" + strings.Join(names, ",") + "]"
+	key := g.ctx.expPrefix(name)
+	idx := c.Globals.Index(key)
+	if g.done[key] {
+		return idx
+	}
+	g.done[key] = true
+	if g.decl.Symbol == "type" {
+		g.args[structType(Type(idx))] = args
+	}
+
+	const declName, declParams = 0, 2
+	sub := g.ctx
+	sub.Locals = newLookup()
+	sub.Instances = c.Instances
+	sub.bind(key, g.params, args)
+	decl := g.decl.Copy()
+	decl.Tokens[declName].Text = name
+	decl.Tokens = decl.Tokens[:declParams]
+	res := sub.compile(decl)
+	for _, m := range g.methods {
+		const methodType, methodFunc = 0, 2
+		m = m.Copy()
+		m.Tokens[methodType].Text = name
+		recv := elemType(m.Tokens[methodFunc].Tokens[0].Tokens[0].Tokens[0])
+		sub.bind(key, typeParams(plural(recv.Tokens[1])), args)
+		res = append(res, sub.compile(m)...)
+	}
+	*c.Instances = append(*c.Instances, res...)
+	return idx
+}
+
+func (c *compiler) bind(key string, params []string, args []Type) {
+	c.TypeParams = map[string]string{}
+	for i, p := range params {
+		k := key + "." + p
+		c.Globals.Set(k, newType(args[i]))
+		c.TypeParams[p] = k
+	}
+}
+
+// unify binds the type parameters in tok by matching it against t.
+func (c *compiler) unify(g *generic, tok *token, t Type, bound map[string]Type) {
+	switch tok.Symbol {
+	case "(name)":
+		if b, ok := bound[tok.Text]; ok && (b != untypedInt || t == untypedInt) { // typed args win over untyped constants
+			return
+		}
+		for _, p := range g.params {
+			if p == tok.Text {
+				bound[p] = t
+			}
+		}
+	case "[]", "...":
+		if t.base() == TypeSlice {
+			c.unify(g, tok.Tokens[0], t.value(), bound)
+		}
+	case "*":
+		c.unify(g, tok.Tokens[0], t, bound)
+	case "map":
+		if t.base() == TypeMap {
+			k, v := t.pair()
+			c.unify(g, tok.Tokens[0], k, bound)
+			c.unify(g, tok.Tokens[1], v, bound)
+		}
+	case "index":
+		if tg := c.generic(tok.Tokens[0]); tg != nil {
+			for i, a := range tg.args[t&^valueMask] {
+				c.unify(g, plural(tok.Tokens[1]).Tokens[i], a, bound)
+			}
+		}
+	}
+}
+
+// unifyValue is unify with the element types of packed variadic args and
+// the signatures of funcs.
+func (c *compiler) unifyValue(g *generic, tok *token, v Value, bound map[string]Type) {
+	switch f := v.value.(type) {
+	case nil:
+		if v.t == TypeNil {
+			return
+		}
+	case *sliceT:
+		if tok.Symbol == "..." && f.valueType == TypeNil {
+			for _, e := range f.data {
+				c.unifyValue(g, tok.Tokens[0], e, bound)
+			}
+			return
+		}
+	case *funcT:
+		const funcArguments, funcReturns = 0, 1
+		if tok.Symbol != "func" || len(f.sig) != f.Args+len(tok.Tokens[funcReturns].Tokens) {
+			return
+		}
+		for i, arg := range tok.Tokens[funcArguments].Tokens {
+			c.unify(g, arg.Tokens[0], Type(f.sig[i].A), bound)
+		}
+		for i, ret := range tok.Tokens[funcReturns].Tokens {
+			c.unify(g, ret, Type(f.sig[f.Args+i].A), bound)
+		}
+		return
+	}
+	c.unify(g, tok, v.t, bound)
+}
+
+// infer binds the type arguments of a call to g from the static types of
+// args, false when some are only known at run time, see dispatch.
+func (c *compiler) infer(g *generic, args []*token) ([]Type, bool) {
+	const funcArguments, funcReturns = 0, 1
+	params := g.decl.Tokens[1].Tokens[funcArguments].Tokens
+	ctx := g.ctx
+	ctx.Locals = newLookup()
+	bound := map[string]Type{}
+	for i, arg := range args {
+		if i >= len(params) && (len(params) == 0 || params[len(params)-1].Tokens[0].Symbol != "...") {
+			return nil, false
+		}
+		p := params[len(params)-1].Tokens[0]
+		if i < len(params)-1 {
+			p = params[i].Tokens[0]
+		}
+		if arg.Symbol == "..." {
+			arg = arg.Tokens[0]
+		} else if p.Symbol == "..." {
+			p = p.Tokens[0]
+		}
+		if arg.Symbol == "nil" { // binds nothing
+			continue
+		}
+		if arg.Symbol == "lambda" {
+			fn := arg.Tokens[0]
+			if p.Symbol != "func" || len(p.Tokens[funcArguments].Tokens) != len(fn.Tokens[funcArguments].Tokens) || len(p.Tokens[funcReturns].Tokens) != len(fn.Tokens[funcReturns].Tokens) {
+				return nil, false
+			}
+			for j, a := range fn.Tokens[funcArguments].Tokens {
+				ctx.unify(g, p.Tokens[funcArguments].Tokens[j].Tokens[0], typeFromToken(c, a.Tokens[0]), bound)
+			}
+			for j, r := range fn.Tokens[funcReturns].Tokens {
+				ctx.unify(g, p.Tokens[funcReturns].Tokens[j], typeFromToken(c, r), bound)
+			}
+			continue
+		}
+		t, ok := c.staticType(arg)
+		if !ok {
+			return nil, false
+		}
+		ctx.unify(g, p, t, bound)
+	}
+	if len(args) < len(params) {
+		return nil, false
+	}
+	return g.inferred(bound), true
+}
+
+// inferred returns the type arguments in bound, untyped constants get
+// their default type.
+func (g *generic) inferred(bound map[string]Type) []Type {
+	types := make([]Type, len(g.params))
+	for i, p := range g.params {
+		t, ok := bound[p]
+		if !ok {
+			panicf("in call to %v, cannot infer %v", g.name, p)
+		}
+		if t == untypedInt {
+			t = TypeInt32
+		}
+		types[i] = t
+	}
+	return types
+}
+
+// dispatch returns a func that infers the type arguments of g from the
+// call arguments and calls the matching instance, for calls infer could
+// not resolve. Instances are cached by argument types.
+func (g *generic) dispatch() Value {
+	const funcArguments, funcReturns = 0, 1
+	fn := g.decl.Tokens[1]
+	args := fn.Tokens[funcArguments].Tokens
+	argc := len(args)
+	variadic := argc > 0 && args[argc-1].Tokens[0].Symbol == "..."
+	if variadic {
+		argc = -argc
+	}
+	cache := map[string]*funcT{}
+	return newFunc(argc, len(fn.Tokens[funcReturns].Tokens), func(v *VM) {
+		stack := v.stack[len(v.stack)-len(args):]
+		var buf [64]byte
+		key, cacheable := buf[:0], true
+		for i, a := range stack {
+			switch s := a.value.(type) {
+			case *funcT: // see unifyValue
+				cacheable = false
+			case *sliceT:
+				cacheable = cacheable && !(variadic && i == len(stack)-1 && s.valueType == TypeNil)
+			}
+			key = binary.LittleEndian.AppendUint64(key, uint64(a.t))
+		}
+		f, ok := cache[string(key)]
+		if !ok {
+			c := g.ctx
+			c.Locals = newLookup()
+			bound := map[string]Type{}
+			for i, arg := range args {
+				c.unifyValue(g, arg.Tokens[0], stack[i], bound)
+			}
+			f = g.instance(v, &c, g.inferred(bound))
+			if cacheable {
+				cache[string(key)] = f
+			}
+		}
+		if variadic {
+			if s, ok := stack[len(args)-1].value.(*sliceT); ok && s.valueType == TypeNil {
+				stack[len(args)-1] = NewSlice(f.VariadicType.value(), s.data)
+			}
+		}
+		f.Value(v)
+	})
+}
+
+// instance returns the func for types, compiling and running it if needed.
+func (g *generic) instance(v *VM, c *compiler, types []Type) *funcT {
+	var pending []instruction
+	c.Instances = &pending
+	key := c.Globals.Key(c.instantiate(g, types))
+	if f, ok := g.funcs[key]; ok {
+		return f
+	}
+	if len(pending) > 0 {
+		if _, err := v.run(pending, c.Locals.Cap()); err != nil {
+			panic(err)
+		}
+	}
+	f := v.globals.Get(key).getFunc()
+	g.funcs[key] = f
+	return f
+}
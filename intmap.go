@@ -33,17 +33,11 @@ func newIntMap(alloc int) intMap {
 func (m *intMap) Copy() intMap {
 	pairs := make([]intMapPair, len(m.pairs))
 	copy(pairs, m.pairs)
-	// for i := 0; i < len(pairs); i++ {
-	// 	p := m.pairs[i]
-	// 	if p.distance == 0 {
-	// 		continue
-	// 	}
-	// 	pairs[i] = intMapPair{
-	// 		distance: p.distance,
-	// 		key:      p.key,
-	// 		value:    p.value.copy(),
-	// 	}
-	// }
+	for i := range pairs {
+		if v := pairs[i].value; v.t.isValue() {
+			pairs[i].value = v.copy(v.t)
+		}
+	}
 	return intMap{
 		pairs: pairs,
 		total: m.total,
@@ -79,17 +73,18 @@ func (m *intMap) Set(key int, value Value) {
 	}
 }
 
-func (m *intMap) Assign(key int, value Value) {
+// Assign sets an existing key, false when there is none.
+func (m *intMap) Assign(key int, value Value) bool {
 	hash := intMapHash(key)
 	i := hash
 	for {
 		i &= m.mask
 		if m.pairs[i].distance == 0 {
-			return
+			return false
 		}
 		if m.pairs[i].key == key {
 			m.pairs[i].value = value.assign(m.pairs[i].value.t)
-			return
+			return true
 		}
 		i++
 	}
@@ -98,6 +98,10 @@ func TestLoadPackage_Exec(t *testing.T) {
 			"main/z.go":       `package main; import "fmt"`,
 			"test/ext/pkg.go": `package ext; import ( "fmt" "math" ); func F() int { return 42 }`,
 		}, `42`},
+		{"generics", "main", mapFS{
+			"main/main.go":    `package main; import "example.com/test/ext"; func test() any { s := &ext.Stack[int]{}; s.Push(ext.Map([]string{"ab"}, ext.Len)...); return s.Items }`,
+			"test/ext/pkg.go": `package ext; func Len(s string) int { return len(s) }; func Map[T, U any](s []T, f func(T) U) []U { res := make([]U, 0); for _, v := range s { res = append(res, f(v)) }; return res }; func (s *Stack[T]) Push(v ...T) { s.Items = append(s.Items, v...) }; type Stack[T any] struct { Items []T }`,
+		}, `[2]`},
 	}
 	for _, row := range tests {
 		t.Run(row.Name, func(t *testing.T) {
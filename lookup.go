@@ -1,10 +1,27 @@
 package goatlang
 
+import (
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
 type lookup struct {
 	keyToIndex map[string]int
 	indexToKey []string
 	data       []Value
 	cap        int
+	methods    map[[2]int]int  // type and method name -> method global, -1 on structs, see Value.method
+	ptrs       map[[2]int]bool // methods with a pointer receiver, see structT.hasMethod
+	ifaces     map[*structT]*methodSet
+	types      map[int]Type // declared types of variables, see compiler.staticType
+}
+
+// methodSet is the sorted method names of an interface and the types known
+// to implement it, see VM.implements.
+type methodSet struct {
+	names []string
+	keys  []int
+	ok    map[Type]bool
 }
 
 func newLookup() *lookup {
@@ -108,3 +125,63 @@ func (l *lookup) Exists(key string) bool {
 func (l *lookup) Key(index int) string {
 	return l.indexToKey[index]
 }
+
+// setType records the declared type of the variable at index, or forgets
+// it when not known.
+func (l *lookup) setType(index int, t Type, known bool) {
+	if l.types == nil {
+		l.types = map[int]Type{}
+	}
+	if !known {
+		delete(l.types, index)
+		return
+	}
+	l.types[index] = t
+}
+
+func (l *lookup) typeOf(index int) (Type, bool) {
+	t, ok := l.types[index]
+	return t, ok
+}
+
+// setMethod records that the method k of the type n is held by the global
+// at index, or by the struct n when index is -1. ptr is set for a pointer
+// receiver.
+func (l *lookup) setMethod(n, k, index int, ptr bool) {
+	if l.methods == nil {
+		l.methods = map[[2]int]int{}
+		l.ptrs = map[[2]int]bool{}
+	}
+	l.methods[[2]int{n, k}] = index
+	l.ptrs[[2]int{n, k}] = ptr
+}
+
+// ptrMethod reports whether the method k of the type n has a pointer
+// receiver, so values of n lack it.
+func (l *lookup) ptrMethod(n, k int) bool {
+	return l.ptrs[[2]int{n, k}]
+}
+
+// methodSet returns the method set of the interface iface, built once.
+func (l *lookup) methodSet(iface *structT) *methodSet {
+	if m, ok := l.ifaces[iface]; ok {
+		return m
+	}
+	if l.ifaces == nil {
+		l.ifaces = map[*structT]*methodSet{}
+	}
+	m := &methodSet{names: maps.Keys(iface.Lookup), ok: map[Type]bool{}}
+	slices.Sort(m.names)
+	for _, name := range m.names {
+		m.keys = append(m.keys, iface.Lookup[name])
+	}
+	l.ifaces[iface] = m
+	return m
+}
+
+// method returns the index of the global holding the method k of the type
+// n, see setMethod.
+func (l *lookup) method(n, k int) (int, bool) {
+	index, ok := l.methods[[2]int{n, k}]
+	return index, ok
+}
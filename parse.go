@@ -2,6 +2,7 @@ package goatlang
 
 import (
 	"fmt"
+	"strings"
 	"text/scanner"
 
 	"golang.org/x/exp/slices"
@@ -27,11 +28,12 @@ func parse(tokens []*token) (res *token, err error) {
 }
 
 type parser struct {
-	Token  *token
-	Tokens []*token
-	N      int
-	mask   []string
-	Depth  int
+	Token   *token
+	Tokens  []*token
+	N       int
+	mask    []string
+	Depth   int
+	results *token // named results of the func being parsed, see returnNud
 }
 
 func symAtPos(pos scanner.Position, symbol string) *token {
@@ -52,6 +54,17 @@ func (p *parser) Advance(sym string) *token {
 }
 
 func (p *parser) Statement() *token {
+	if p.Token.Symbol == "(name)" && p.Tokens[p.N].Symbol == ":" {
+		label := symAtPos(p.Token.Pos, "label")
+		label.Text = p.Advance("(name)").Text
+		p.Advance(":")
+		if p.Token.Symbol != "}" {
+			if tok := p.Statement(); tok != nil {
+				label.Append(tok)
+			}
+		}
+		return label
+	}
 	tok := p.Expression(0)
 	if tok == nil {
 		return nil
@@ -94,10 +107,31 @@ func (p *parser) doExpression(rbp int) *token {
 	t := p.Token
 	p.Next()
 	left := getSymbol(t).Nud(p, t)
-	for rbp < getSymbol(p.Token).Lbp && !slices.Contains(p.mask, p.Token.Symbol) {
+	if left == nil { // e.g. ";" between statements
+		return nil
+	}
+	for rbp < getSymbol(p.Token).Lbp && !slices.Contains(p.mask, p.Token.Symbol) && !p.lineEnded() {
 		t = p.Token
 		p.Next()
 		left = getSymbol(t).Led(p, t, left)
 	}
 	return left
 }
+
+// lineEnded reports whether Go inserts a ";" before a "*" on a new line, so
+// `*p = x` there starts a statement instead of multiplying the last one.
+func (p *parser) lineEnded() bool {
+	if p.Token.Symbol != "*" || p.N < 2 {
+		return false
+	}
+	prev := p.Tokens[p.N-2]
+	if prev.Pos.Line+strings.Count(prev.Text, "\n") == p.Token.Pos.Line {
+		return false
+	}
+	switch prev.Symbol {
+	case "(name)", "(int)", "(float)", "(char)", "(string)", "true", "false", "nil", "break", "continue", "return", ")", "]", "}", "++", "--",
+		"any", "float32", "float64", "int", "int32", "uint32", "uint", "rune", "byte", "uint8", "int8", "uint16", "int16", "uint64", "int64", "bool", "string", "error":
+		return true
+	}
+	return false
+}
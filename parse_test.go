@@ -55,7 +55,7 @@ func TestParse(t *testing.T) {
 		{"varMapSlice", "var a map[string][]int", `(var (, (a (map string ([] int)))) ,)`},
 		{"varNamedType", "var a T", `(var (, (a T)) ,)`},
 		{"varAssign", "var a = T", `(:= (, a) T)`},
-		{"varNamedPtrType", "var a *P", `(var (, (a P)) ,)`},
+		{"varNamedPtrType", "var a *P", `(var (, (a (* P))) ,)`},
 
 		{"slice", "[]int{1,2,3}", `([] int (; 1 2 3))`},
 		{"map", `map[string]int{"a":1,"b":2}`, `(map string int (: "a" 1 "b" 2))`},
@@ -81,6 +81,8 @@ func TestParse(t *testing.T) {
 		{"importLong", `import "math/rand"`, `(import rand "math/rand")`},
 
 		{"dot", `a.b.c`, `(. (. a b) c)`},
+		{"assert", `x := a.(*ext.T)`, `(:= (, x) (assert a (* (. ext T))))`},
+		{"assertOk", `x, ok := a.(I)`, `(:= (, x ok) (assertOk a I))`},
 		{"negateNumber", `-42`, `-42`},
 		{"negateVar", `-a`, `(negate a)`},
 		{"negate", "x := -42; y := -x;", `(:= (, x) -42) (:= (, y) (negate x))`},
@@ -91,15 +93,16 @@ func TestParse(t *testing.T) {
 		{"not", `!x`, `(! x)`},
 		{"byteConvert", `[]byte("*")`, `(call ([] byte) (arguments "*") 0)`},
 		{"typeStruct", `type T struct { X,Y,Z int; Name string }`, `(type T (struct X int Y int Z int Name string))`},
-		{"newData1", `v := &T{ X:1, Y:2, Z:3, Name:"42"}`, `(:= (, v) (new T (: X 1 Y 2 Z 3 Name "42")))`},
-		{"newData2", `import "ext"; v := &ext.T{ X:1, Y:2, Z:3, Name:"42"}`, `(import ext "ext") (:= (, v) (new (. ext T) (: X 1 Y 2 Z 3 Name "42")))`},
-		{"method1", `func (t *T) test(a, b int) {}`, `(method T test (func (arguments (t T) (a int) (b int)) returns block))`},
+		{"typeStructTags", "type T struct { ID int `json:\"id\"`; X, Y int `json:\"-\"`; Name string }", "(type T (struct (ID `json:\"id\"`) int (X `json:\"-\"`) int (Y `json:\"-\"`) int Name string))"},
+		{"newData1", `v := &T{ X:1, Y:2, Z:3, Name:"42"}`, `(:= (, v) (address (new T (: X 1 Y 2 Z 3 Name "42"))))`},
+		{"newData2", `import "ext"; v := &ext.T{ X:1, Y:2, Z:3, Name:"42"}`, `(import ext "ext") (:= (, v) (address (new (. ext T) (: X 1 Y 2 Z 3 Name "42"))))`},
+		{"method1", `func (t *T) test(a, b int) {}`, `(method T test (func (arguments (t (* T)) (a int) (b int)) returns block))`},
 		{"method2", `func (t T) test(a, b int) {}`, `(method T test (func (arguments (t T) (a int) (b int)) returns block))`},
 		{"methodCall", `obj.test(1,2,3)`, `(call (. obj test) (arguments 1 2 3) 0)`},
 		{"extraCommaSlice", `[]int{1,2,3,}`, `([] int (; 1 2 3))`},
 		{"extraCommaMap", `map[int]int{1:1,2:2,3:3,}`, `(map int int (: 1 1 2 2 3 3))`},
 		{"extraCommaArgs", `f(1,2,3,)`, `(call f (arguments 1 2 3) 0)`},
-		{"extraCommaStruct", `&T{a:1,b:2,c:3,}`, `(new T (: a 1 b 2 c 3))`},
+		{"extraCommaStruct", `&T{a:1,b:2,c:3,}`, `(address (new T (: a 1 b 2 c 3)))`},
 		{"typeInterface", `type T interface { X(k int) int ; Z(k int) ; Y(k string)int }`, `(type T (interface X (arguments (k int)) (returns int) Z (arguments (k int)) returns Y (arguments (k string)) (returns int)))`},
 		{"typeInterfaceBlanks", `type T interface { X(int) }`, `(type T (interface X (arguments (_ int)) returns))`},
 		{"typeInterfaceNamed", `type T interface { X(t int) ; Y() }`, `(type T (interface X (arguments (t int)) returns Y arguments returns))`},
@@ -112,64 +115,78 @@ func TestParse(t *testing.T) {
 		{"nilAssign", "a = nil", `(= (, a) nil)`},
 		{"makeSlice", "make([]int, 42)", `(make ([] int) 42)`},
 		{"makeMap", "make(map[int]string)", `(make (map int string))`},
-		{"structSliceInit", "x := []*T{&T{X:1}}", `(:= (, x) ([] T (; (new T (: X 1)))))`},
-		{"structSliceAutoInit", "x := []*T{{X:1}}", `(:= (, x) ([] T (; (: X 1))))`},
-		{"manyStructSliceAutoInit", "x := []*T{{X:1},{X:2}}", `(:= (, x) ([] T (; (: X 1) (: X 2))))`},
-		{"forAppend", `for i:=0; i<3; i++ { res = append(res,&T{X:i}) }`, `(for (:= (, i) 0) (< i 3) (++ i) (block (= (, res) (call append (arguments res (new T (: X i))) 1))))`},
-		{"structMapAutoInit", "x := map[int]*T{42:{X:1}}", `(:= (, x) (map int T (: 42 (: X 1))))`},
+		{"makeSliceCap", "make([]int, 0, n)", `(make ([] int) 0 n)`},
+		{"makeMapHint", "make(map[int]string, 8)", `(make (map int string) 8)`},
+		{"structSliceInit", "x := []*T{&T{X:1}}", `(:= (, x) ([] (* T) (; (address (new T (: X 1))))))`},
+		{"structSliceAutoInit", "x := []*T{{X:1}}", `(:= (, x) ([] (* T) (; (: X 1))))`},
+		{"manyStructSliceAutoInit", "x := []*T{{X:1},{X:2}}", `(:= (, x) ([] (* T) (; (: X 1) (: X 2))))`},
+		{"forAppend", `for i:=0; i<3; i++ { res = append(res,&T{X:i}) }`, `(for (:= (, i) 0) (< i 3) (++ i) (block (= (, res) (call append (arguments res (address (new T (: X i)))) 1))))`},
+		{"structMapAutoInit", "x := map[int]*T{42:{X:1}}", `(:= (, x) (map int (* T) (: 42 (: X 1))))`},
 		{"varFunc", `var x func() int`, `(var (, (x (func arguments (returns int)))) ,)`},
 		{"switchValue", `switch v { case 1: 41; case 2: 42; default: 43 }`, `(switch v (, (case 1 (block 41)) (case 2 (block 42))) (default (block 43)))`},
 		{"switchTrue", `switch { case false: 42; case true: 42; default: 43; }`, `(switch ~ (, (case false (block 42)) (case true (block 42))) (default (block 43)))`},
 		{"switchTrueNoDefault", `switch { case 2: 42;}`, `(switch ~ (, (case 2 (block 42))) ~)`},
 		{"stackIndex", "$0", `(index $ 0)`},
 		{"stackRegular", "$[0]", `(index $ 0)`},
-		{"pkgTypeVar", `import "ext"; var x *ext.T`, `(import ext "ext") (var (, (x (. ext T))) ,)`},
-		{"pkgTypeArg", `import "ext"; func f(x *ext.T) { }`, `(import ext "ext") (function f (func (arguments (x (. ext T))) returns block))`},
+		{"pkgTypeVar", `import "ext"; var x *ext.T`, `(import ext "ext") (var (, (x (* (. ext T)))) ,)`},
+		{"pkgTypeArg", `import "ext"; func f(x *ext.T) { }`, `(import ext "ext") (function f (func (arguments (x (* (. ext T)))) returns block))`},
 		{"2dSliceAutoInit", `x := [][]int{{1,2,3},{4,5,6}}`, `(:= (, x) ([] ([] int) (; (; 1 2 3) (; 4 5 6))))`},
 		{"3dSliceAutoInit", `x := [][][]int{{{1,2,3},{2,3,4}},{{3,4,5}}}`, `(:= (, x) ([] ([] ([] int)) (; (; (; 1 2 3) (; 2 3 4)) (; (; 3 4 5)))))`},
 		{"mapSliceAutoInit", `x := map[int][]int{1:{1,2,3},2:{2,3,4}}`, `(:= (, x) (map int ([] int) (: 1 (; 1 2 3) 2 (; 2 3 4))))`},
 		{"sliceMapAutoInit", `x := []map[int]int{{1:2},{3:4}}`, `(:= (, x) ([] (map int int) (; (: 1 2) (: 3 4))))`},
-		{"sliceStructAutoInit", `x := []*T{{X:42},{X:43}}`, `(:= (, x) ([] T (; (: X 42) (: X 43))))`},
+		{"sliceStructAutoInit", `x := []*T{{X:42},{X:43}}`, `(:= (, x) ([] (* T) (; (: X 42) (: X 43))))`},
 		{"sliceTypeAutoInit", `x := []T{42,43,44}`, `(:= (, x) ([] T (; 42 43 44)))`},
 		{"retMultiRet", `func g() (int,int) { return f() }`, `(function g (func arguments (returns int int) (block (return (call f arguments -1)))))`},
 		{"initFunc", "func init() { x = 42 }", `(init (func arguments returns (block (= (, x) 42))))`},
 		{"errReturn", `func f() error { return errors.New("42") }`, `(function f (func arguments (returns error) (block (return (call (. errors New) (arguments "42") -1)))))`},
 		{"funcParam", `func f(g func(), h func(string) int) { }`, `(function f (func (arguments (g (func arguments returns)) (h (func (arguments (_ string)) (returns int)))) returns block))`},
-		{"fancyFuncParam", `func Run(tick func(), event func(*Event)) { }`, `(function Run (func (arguments (tick (func arguments returns)) (event (func (arguments (_ Event)) returns))) returns block))`},
+		{"fancyFuncParam", `func Run(tick func(), event func(*Event)) { }`, `(function Run (func (arguments (tick (func arguments returns)) (event (func (arguments (_ (* Event))) returns))) returns block))`},
 		{"fmtPrintlnHack", `fmt.Println(4,2)`, `(call (. fmt Println) (arguments 4 2) 0)`},
 		{"fmtPrintHack", `fmt.Print(4,2)`, `(call (. fmt Print) (arguments 4 2) 0)`},
 		{"intTypes", `type T int; var t T; t = T(42)`, `(type T int) (var (, (t T)) ,) (= (, t) (call T (arguments 42) 1))`},
 		{"constAuto", `const (A = 42;B;C)`, `(const (, A B C) (, 42 42 42))`},
 		{"constIota", `const (A = iota + 42; B; C)`, `(const (, A B C) (, (+ 0 42) (+ 1 42) (+ 2 42)))`},
 		{"constIotaNL", "const (\nA = iota\nB\nC\n)", `(const (, A B C) (, 0 1 2))`},
+		{"constIotaType", `const (A uint8 = iota; B)`, `(const (, (A uint8) (B uint8)) (, 0 1))`},
 		{"panic", `panic("hello")`, `(call panic (arguments "hello") 0)`},
 		{"copy", `copy(a,b)`, `(call copy (arguments a b) 0)`},
 		{"convertByte", `var x = byte(42)`, `(:= (, x) (call byte (arguments 42) 1))`},
 		{"varByte", `var x byte`, `(var (, (x byte)) ,)`},
 		{"varByteAssign", `var x byte = 42`, `(var (, (x byte)) 42)`},
-		{"makeSliceStruct", `make([]*T,n)`, `(make ([] T) n)`},
+		{"makeSliceStruct", `make([]*T,n)`, `(make ([] (* T)) n)`},
 		{"callCallNegativeBug", `f(-1).m()`, `(call (. (call f (arguments -1) 1) m) arguments 0)`},
-		{"structPointer", `x := &T{K:42}`, `(:= (, x) (new T (: K 42)))`},
-		{"extStructPointer", `x := &ext.T{K:42}`, `(:= (, x) (new (. ext T) (: K 42)))`},
-		{"!structNonPointer", `x := T{K:42}`, `(:= (, x) (new T (: K 42)))`},
-		{"!extStructNonPointer", `x := ext.T{K:42}`, `(:= (, x) (new (. ext T) (: K 42)))`},
-		{"sliceStructPointer", `[]*T{{K:42}}`, `([] T (; (: K 42)))`},
-		{"!sliceStructNonPointer", `[]T{{K:42}}`, `([] T (; (: K 42)))`},
-		{"sliceExtStructPointer", `[]*ext.T{{K:42}}`, `([] (. ext T) (; (: K 42)))`},
-		{"!sliceExtStructNonPointer", `[]ext.T{{K:42}}`, `([] (. ext T) (; (: K 42)))`},
-		{"mapStructPointer", `map[int]*T{0:{K:42}}`, `(map int T (: 0 (: K 42)))`},
-		{"!mapStructNonPointer", `map[int]T{0:{K:42}}`, `(map int T (: 0 (: K 42)))`},
-		{"mapExtStructPointer", `map[int]*ext.T{0:{K:42}}`, `(map int (. ext T) (: 0 (: K 42)))`},
-		{"!mapExtStructNonPointer", `map[int]ext.T{0:{K:42}}`, `(map int (. ext T) (: 0 (: K 42)))`},
-		{"!ignoreRefs", `&x`, `x`},
-		{"!ignoreDerefs", `*x`, `x`},
+		{"structPointer", `x := &T{K:42}`, `(:= (, x) (address (new T (: K 42))))`},
+		{"extStructPointer", `x := &ext.T{K:42}`, `(:= (, x) (address (new (. ext T) (: K 42))))`},
+		{"structNonPointer", `x := T{K:42}`, `(:= (, x) (new T (: K 42)))`},
+		{"extStructNonPointer", `x := ext.T{K:42}`, `(:= (, x) (new (. ext T) (: K 42)))`},
+		{"sliceStructPointer", `[]*T{{K:42}}`, `([] (* T) (; (: K 42)))`},
+		{"sliceStructNonPointer", `[]T{{K:42}}`, `([] T (; (: K 42)))`},
+		{"sliceExtStructPointer", `[]*ext.T{{K:42}}`, `([] (* (. ext T)) (; (: K 42)))`},
+		{"sliceExtStructNonPointer", `[]ext.T{{K:42}}`, `([] (. ext T) (; (: K 42)))`},
+		{"mapStructPointer", `map[int]*T{0:{K:42}}`, `(map int (* T) (: 0 (: K 42)))`},
+		{"mapStructNonPointer", `map[int]T{0:{K:42}}`, `(map int T (: 0 (: K 42)))`},
+		{"mapExtStructPointer", `map[int]*ext.T{0:{K:42}}`, `(map int (* (. ext T)) (: 0 (: K 42)))`},
+		{"mapExtStructNonPointer", `map[int]ext.T{0:{K:42}}`, `(map int (. ext T) (: 0 (: K 42)))`},
+		{"address", `&x`, `(address x)`},
+		{"deref", `*x`, `(deref x)`},
+		{"derefAssign", `x := 1; *p = x`, `(:= (, x) 1) (= (, (deref p)) x)`},
+		{"derefNewline", "x := 1\n*p = x", `(:= (, x) 1) (= (, (deref p)) x)`},
+		{"arrayType", `var a [3]int`, `(var (, (a (array 3 int))) ,)`},
+		{"arrayEllipsis", `[...]int{1, 2}`, `(array 2 int (; 1 2))`},
+		{"arrayNested", `[2][2]int{{1}, {2, 3}}`, `(array 2 (array 2 int) (; (; 1) (; 2 3)))`},
+		{"arrayArg", `func f(a [2]int, b List[T]) {}`, `(function f (func (arguments (a (array 2 int)) (b (index List (, T)))) returns block))`},
 		{"negateStructConfusion", `if X < -128 { 42 }`, `(if ~ (< X -128) (block 42))`},
 		{"notStructConfusion", `if !visible { 42 }`, `(if ~ (! visible) (block 42))`},
 		{"nlFuncSignature", `func test(
 			x int) bool { 42 }`, `(function test (func (arguments (x int)) (returns bool) (block 42)))`},
 		{"caseReturn", `switch x { case A: return x ; case B: }`, `(switch x (, (case A (block (return x))) (case B block)) ~)`},
+		{"label", `outer: for { continue outer }`, `(outer (for ~ ~ ~ (block (continue outer))))`},
+		{"labelEnd", `func f() { done: }`, `(function f (func arguments returns (block done)))`},
+		{"goto", `goto done`, `(goto done)`},
+		{"namedResults", `func f() (n int, err error) { return }`, `(function f (func arguments (returns int error) (block (var (, (n int) (err error)) ,) (return n err))))`},
+		{"namedResultsGrouped", `func f() (a, b int) { return 1, 2 }`, `(function f (func arguments (returns int int) (block (var (, (a int) (b int)) ,) (return 1 2))))`},
 		{"iotaCast", `const (codeBreak = code(-(iota + 1)))`, `(const (, codeBreak) (, (call code (arguments (negate (+ 0 1))) 1)))`},
-		{"memberInc", `type T struct { N int }; func (t *T) F() { t.N ++ }`, `(type T (struct N int)) (method T F (func (arguments (t T)) returns (block (++ (. t N)))))`},
+		{"memberInc", `type T struct { N int }; func (t *T) F() { t.N ++ }`, `(type T (struct N int)) (method T F (func (arguments (t (* T))) returns (block (++ (. t N)))))`},
 		{"constRefConst", `const (a = 40; b = a+2 )`, `(const (, a b) (, 40 (+ a 2)))`},
 		{"constWeirdBug", `const a = -42; const b=-a`, `(const (, a) -42) (const (, b) (negate a))`},
 		{"funcEllipsis", `func f(a int, b ...int) { }`, `(function f (func (arguments (a int) (b (... int))) returns block))`},
@@ -177,11 +194,26 @@ func TestParse(t *testing.T) {
 		{"fancyTypeAlias", `type Matrix []float64 ; x := Matrix{1,2,3}`, `(type Matrix ([] float64)) (:= (, x) (new Matrix (; 1 2 3)))`},
 		{"typeAliasExact", `type A = T`, `(type A T)`},
 		{"typeAliasMakeSlice", `x = make(Matrix, 16)`, `(= (, x) (make Matrix 16))`},
-		{"sliceStructInit", `s := []*T{&T{X:42}}`, `(:= (, s) ([] T (; (new T (: X 42)))))`},
+		{"sliceStructInit", `s := []*T{&T{X:42}}`, `(:= (, s) ([] (* T) (; (address (new T (: X 42))))))`},
 		{"typeAliasInit", `x := A{{X:42}}`, `(:= (, x) (new A (; (: X 42))))`},
-		{"sliceBug", `f := []*F{p2f([]*P{a}),}`, `(:= (, f) ([] F (; (call p2f (arguments ([] P (; a))) 1))))`},
+		{"sliceBug", `f := []*F{p2f([]*P{a}),}`, `(:= (, f) ([] (* F) (; (call p2f (arguments ([] (* P) (; a))) 1))))`},
 
 		{"lambdaFunc", `func f() func() int { return func() int { return 42 }}`, `(function f (func arguments (returns (func arguments (returns int))) (block (return (lambda (func arguments (returns int) (block (return 42))))))))`},
+
+		{"genericFunc", `func F[K comparable, V any](m map[K]V) {}`, `(function F (func (arguments (m (map K V))) returns block) (typeparams K V))`},
+		{"genericConstraint", `func F[T ~int | ~float64, S ~[]T](s S) {}`, `(function F (func (arguments (s S)) returns block) (typeparams T S))`},
+		{"genericType", `type S[T any] struct { x T }`, `(type S (struct x T) (typeparams T))`},
+		{"genericMethod", `func (s *S[T]) F() {}`, `(method S F (func (arguments (s (* (index S (, T))))) returns block))`},
+		{"typeArgs", `x := F[int, []string]()`, `(:= (, x) (call (index F (, int ([] string))) arguments 1))`},
+		{"typeArgsInit", `x := &S[ext.T]{}`, `(:= (, x) (address (new (index S (. ext T)) ;)))`},
+		{"funcTypeArgs", `var f func(T) U`, `(var (, (f (func (arguments (_ T)) (returns U)))) ,)`},
+		{"typeSetInterface", `type Number interface { ~int | ~float64; String() string }`, `(type Number (interface String arguments (returns string)))`},
+		{"typeInterfaceEmbed", `type RC interface { Reader; io.Closer; Extra() }`, `(type RC (interface (Reader Reader) (Closer (. io Closer)) Extra arguments returns))`},
+		{"embedded", `type T struct { A; *B; ext.C "tag"; HP int }`, `(type T (struct A A B (* B) (C "tag") (. ext C) HP int))`},
+		{"embeddedLines", `type T struct {
+			A
+			HP int
+		}`, `(type T (struct A A HP int))`},
 	}
 	for _, row := range tests {
 		t.Run(row.Name, func(t *testing.T) {
@@ -212,6 +244,7 @@ func TestParse_error(t *testing.T) {
 		{"advance", "f(z}", "advance got"},
 		{"nullLed", "1 ! 2", "null led"},
 		{"type", "[]else", "type: unexpected symbol"},
+		{"gotoLabel", "goto", "goto: missing label"},
 	}
 	for _, row := range tests {
 		t.Run(row.Name, func(t *testing.T) {
@@ -0,0 +1,235 @@
+package goatlang
+
+import (
+	"strings"
+)
+
+// Slices of uint8, int32, float32, float64 and string (and named types of them) are
+// backed by a Go slice of the item type instead of a []Value, so they take
+// a quarter of the memory and convert to Go without copying.
+
+type compactSlice interface {
+	Object
+	capacity() int
+	clear()
+	copyFrom(src Value)
+	update(data []Value) // write back items read with Value.data
+}
+
+type elemCodec[E any] interface {
+	toValue(t Type, e E) Value
+	fromValue(v Value) E
+}
+
+type uint8Codec struct{}
+
+func (uint8Codec) toValue(t Type, e uint8) Value { return Value{t: t, num: float64(e)} }
+func (uint8Codec) fromValue(v Value) uint8       { return toUint8(v.num) }
+
+type int32Codec struct{}
+
+func (int32Codec) toValue(t Type, e int32) Value { return Value{t: t, num: float64(e)} }
+func (int32Codec) fromValue(v Value) int32       { return toInt32(v.num) }
+
+type float32Codec struct{}
+
+func (float32Codec) toValue(t Type, e float32) Value { return Value{t: t, num: float64(e)} }
+func (float32Codec) fromValue(v Value) float32       { return float32(v.num) }
+
+type float64Codec struct{}
+
+func (float64Codec) toValue(t Type, e float64) Value { return Value{t: t, num: e} }
+func (float64Codec) fromValue(v Value) float64       { return v.num }
+
+type stringCodec struct{}
+
+func (stringCodec) toValue(t Type, e string) Value { return Value{t: t, value: stringT(e)} }
+func (stringCodec) fromValue(v Value) string {
+	s, _ := v.value.(stringT)
+	return string(s)
+}
+
+type typedSlice[E any, C elemCodec[E]] struct {
+	Object
+	valueType Type
+	data      []E
+}
+
+// makeSlice returns a slice of t holding data with room for capacity
+// items, compact when t allows it.
+func makeSlice(t Type, data []Value, capacity int) Value {
+	switch t.underlying() {
+	case TypeUint8:
+		return fromValues[uint8, uint8Codec](t, data, capacity)
+	case TypeInt32:
+		return fromValues[int32, int32Codec](t, data, capacity)
+	case TypeFloat32:
+		return fromValues[float32, float32Codec](t, data, capacity)
+	case TypeFloat64:
+		return fromValues[float64, float64Codec](t, data, capacity)
+	case TypeString:
+		return fromValues[string, stringCodec](t, data, capacity)
+	}
+	if cap(data) < capacity {
+		data = append(make([]Value, 0, capacity), data...)
+	}
+	return newSlice(t, data)
+}
+
+func fromValues[E any, C elemCodec[E]](t Type, data []Value, capacity int) Value {
+	var c C
+	if capacity < len(data) {
+		capacity = len(data)
+	}
+	s := make([]E, len(data), capacity)
+	for i, v := range data {
+		s[i] = c.fromValue(v)
+	}
+	return wrapSlice[E, C](t, s)
+}
+
+func wrapSlice[E any, C elemCodec[E]](t Type, data []E) Value {
+	return Value{t: sliceType(t), value: &typedSlice[E, C]{valueType: t, data: data}}
+}
+
+// toGo returns the items of v as a []E, sharing them when v is backed by
+// one.
+func toGo[E any, C elemCodec[E]](v Value) []E {
+	if s, ok := v.value.(*typedSlice[E, C]); ok {
+		return s.data
+	}
+	if v.value == nil {
+		return nil
+	}
+	var c C
+	data := v.data()
+	res := make([]E, len(data))
+	for i, e := range data {
+		res[i] = c.fromValue(e)
+	}
+	return res
+}
+
+// Bytes wraps b as a []byte without copying.
+func Bytes(b []byte) Value { return wrapSlice[uint8, uint8Codec](TypeUint8, b) }
+
+// Int32s wraps s as an []int without copying.
+func Int32s(s []int32) Value { return wrapSlice[int32, int32Codec](TypeInt32, s) }
+
+// Float32s wraps s as a []float32 without copying.
+func Float32s(s []float32) Value { return wrapSlice[float32, float32Codec](TypeFloat32, s) }
+
+// Float64s wraps s as a []float64 without copying.
+func Float64s(s []float64) Value { return wrapSlice[float64, float64Codec](TypeFloat64, s) }
+
+// Strings wraps s as a []string without copying.
+func Strings(s []string) Value { return wrapSlice[string, stringCodec](TypeString, s) }
+
+// Bytes returns the items of a []byte, shared with the script.
+func (v Value) Bytes() []byte { return toGo[uint8, uint8Codec](v) }
+
+// Int32s returns the items of an []int, shared with the script.
+func (v Value) Int32s() []int32 { return toGo[int32, int32Codec](v) }
+
+// Float32s returns the items of a []float32, shared with the script.
+func (v Value) Float32s() []float32 { return toGo[float32, float32Codec](v) }
+
+// Float64s returns the items of a []float64, shared with the script.
+func (v Value) Float64s() []float64 { return toGo[float64, float64Codec](v) }
+
+// Strings returns the items of a []string, shared with the script.
+func (v Value) Strings() []string { return toGo[string, stringCodec](v) }
+
+func (s *typedSlice[E, C]) with(data []E) Value {
+	return wrapSlice[E, C](s.valueType, data)
+}
+
+func (s *typedSlice[E, C]) Len() int      { return len(s.data) }
+func (s *typedSlice[E, C]) capacity() int { return cap(s.data) }
+
+func (s *typedSlice[E, C]) Get(k Value) (Value, bool) {
+	i := k.Int()
+	if uint(i) >= uint(len(s.data)) {
+		panicIndex(i, len(s.data))
+	}
+	var c C
+	return c.toValue(s.valueType, s.data[i]), true
+}
+
+func (s *typedSlice[E, C]) Set(k, v Value) {
+	i := k.Int()
+	if uint(i) >= uint(len(s.data)) {
+		panicIndex(i, len(s.data))
+	}
+	var c C
+	s.data[i] = c.fromValue(v)
+}
+
+func (s *typedSlice[E, C]) Slice(i, j int) Value {
+	if i < 0 || i > j || j > cap(s.data) {
+		panicSlice(i, j, cap(s.data), false)
+	}
+	return s.with(s.data[i:j])
+}
+
+func (s *typedSlice[E, C]) Range() func() (Value, Value, bool) {
+	var c C
+	r := s.data
+	n := 0
+	return func() (Value, Value, bool) {
+		if n >= len(r) {
+			return Nil(), Nil(), false
+		}
+		k, v := Int(n), c.toValue(s.valueType, r[n])
+		n++
+		return k, v, true
+	}
+}
+
+func (s *typedSlice[E, C]) Append(items ...Value) Value {
+	var c C
+	data := s.data
+	for _, v := range items {
+		data = append(data, c.fromValue(v))
+	}
+	return s.with(data)
+}
+
+func (s *typedSlice[E, C]) clear() {
+	var zero E
+	for i := range s.data {
+		s.data[i] = zero
+	}
+}
+
+func (s *typedSlice[E, C]) copyFrom(src Value) {
+	var c C
+	switch o := src.value.(type) {
+	case *typedSlice[E, C]:
+		copy(s.data, o.data)
+	case stringT:
+		for i := 0; i < len(s.data) && i < len(o); i++ {
+			s.data[i] = c.fromValue(Byte(o[i]))
+		}
+	default:
+		s.update(src.data())
+	}
+}
+
+func (s *typedSlice[E, C]) update(data []Value) {
+	var c C
+	for i := 0; i < len(s.data) && i < len(data); i++ {
+		s.data[i] = c.fromValue(data[i])
+	}
+}
+
+func (s *typedSlice[E, C]) String() string {
+	var c C
+	p := make([]string, len(s.data))
+	for i, e := range s.data {
+		p[i] = c.toValue(s.valueType, e).safeStr()
+	}
+	return "[" + strings.Join(p, " ") + "]"
+}
+
+func (s *typedSlice[E, C]) SafeStr() string { return s.String() }
@@ -2,6 +2,7 @@ package goatlang
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"text/scanner"
 )
@@ -18,6 +19,20 @@ func ledInfix(p *parser, t *token, left *token) *token {
 	t.Append(p.doExpression(getSymbol(t).Lbp))
 	return t
 }
+
+// dotLed parses a selector, or a type assertion `x.(T)`.
+func dotLed(p *parser, t *token, left *token) *token {
+	if p.Token.Symbol != "(" {
+		return ledInfix(p, t, left)
+	}
+	p.Advance("(")
+	t = symAtPos(t.Pos, "assert")
+	t.Append(left)
+	t.Append(getType(p))
+	p.Advance(")")
+	return t
+}
+
 func ledPostfix(p *parser, t *token, left *token) *token {
 	t.Append(left)
 	return t
@@ -51,6 +66,9 @@ func assignResize(left, right *token) {
 	if right.Symbol == "index" && len(left.Tokens) > 1 {
 		right.rename("indexOk")
 	}
+	if right.Symbol == "assert" && len(left.Tokens) > 1 {
+		right.rename("assertOk")
+	}
 }
 
 func assignLed(p *parser, t *token, left *token) *token {
@@ -66,7 +84,7 @@ func getArgs(p *parser) (*token, *token) {
 	args := symAtPos(p.Token.Pos, "arguments")
 	for p.Token.Symbol != ")" {
 		var name *token
-		if p.Token.Symbol == "(name)" {
+		if p.Token.Symbol == "(name)" && p.Tokens[p.N].Symbol != "." && (p.Tokens[p.N].Symbol != "[" || isArrayArg(p)) {
 			name = p.Advance("(name)")
 		} else {
 			name = blankAtPos(p.Token.Pos)
@@ -80,6 +98,12 @@ func getArgs(p *parser) (*token, *token) {
 		}
 		p.Advance(",")
 	}
+	if n := len(args.Tokens); n > 0 && len(args.Tokens[n-1].Tokens) == 0 { // only types, e.g. func(T)
+		for i, arg := range args.Tokens {
+			args.Tokens[i] = blankAtPos(arg.Pos)
+			args.Tokens[i].Append(arg)
+		}
+	}
 	var typ *token
 	for i := len(args.Tokens) - 1; i >= 0; i-- {
 		arg := args.Tokens[i]
@@ -94,26 +118,51 @@ func getArgs(p *parser) (*token, *token) {
 	return args, last
 }
 
-func getReturns(last *token, p *parser) *token {
+// isArrayArg reports whether the "[" after a name starts an array type,
+// `a [N]T`, rather than type arguments, `List[T]`.
+func isArrayArg(p *parser) bool {
+	depth := 0
+	for i := p.N; i < len(p.Tokens)-1; i++ {
+		switch p.Tokens[i].Symbol {
+		case "[":
+			depth++
+		case "]":
+			if depth--; depth == 0 {
+				next := p.Tokens[i+1].Symbol
+				return next != "," && next != ")"
+			}
+		}
+	}
+	return false
+}
+
+// getReturns parses the result types, for named results it also returns
+// their declaration, e.g. `(n int, err error)` declares `var n int; var err error`.
+func getReturns(last *token, p *parser) (*token, *token) {
 	returns := symAtPos(p.Token.Pos, "returns")
 	if p.Token.Symbol == "(" {
-		p.Advance("(")
-		for p.Token.Symbol != ")" {
-			returns.Append(getType(p))
-			if p.Token.Symbol != "," {
-				break
+		var results *token
+		args, _ := getArgs(p)
+		for _, arg := range args.Tokens {
+			returns.Append(arg.Tokens[0])
+			if arg.Text != "_" && results == nil {
+				results = symAtPos(returns.Pos, "var")
+				results.Append(symAtPos(returns.Pos, ","))
+				results.Append(symAtPos(returns.Pos, ","))
 			}
-			p.Advance(",")
 		}
-		p.Advance(")")
+		if results != nil {
+			results.Tokens[0].Tokens = args.Tokens
+		}
+		return returns, results
 	} else if p.Token.Symbol != ")" && p.Token.Symbol != "," && p.Token.Symbol != "{" && p.Token.Symbol != "}" && p.Token.Symbol != ";" && p.Token.Pos.Line == last.Pos.Line {
 		returns.Append(getType(p))
 	}
-	return returns
+	return returns, nil
 }
 
 func funcNud(p *parser, t *token) *token {
-	var klass *token
+	var klass, params *token
 	wrap := symAtPos(t.Pos, "function")
 	if p.Depth == 1 {
 		if p.Token.Symbol == "(" {
@@ -121,7 +170,11 @@ func funcNud(p *parser, t *token) *token {
 			klass = tmp.Tokens[0]
 			wrap.rename("method")
 			name := symAtPos(klass.Pos, "(name)")
-			name.Text = klass.Tokens[0].Text
+			recv := elemType(klass.Tokens[0])
+			name.Text = recv.Text
+			if recv.Symbol == "index" { // generic receiver
+				name.Text = recv.Tokens[0].Text
+			}
 			wrap.Append(name)
 			wrap.Append(p.Advance("(name)"))
 		} else {
@@ -130,6 +183,9 @@ func funcNud(p *parser, t *token) *token {
 				wrap = symAtPos(t.Pos, "init")
 			}
 		}
+		if p.Token.Symbol == "[" {
+			params = getTypeParams(p)
+		}
 	} else {
 		wrap.rename("lambda")
 	}
@@ -138,13 +194,64 @@ func funcNud(p *parser, t *token) *token {
 		args.Tokens = append([]*token{klass}, args.Tokens...)
 	}
 	t.Append(args)
-	returns := getReturns(last, p)
+	returns, results := getReturns(last, p)
 	t.Append(returns)
-	t.Append(p.Block("block", "{", "}"))
+	outer := p.results
+	p.results = results
+	block := p.Block("block", "{", "}")
+	p.results = outer
+	if results != nil {
+		block.Tokens = append([]*token{results}, block.Tokens...)
+	}
+	t.Append(block)
 	wrap.Append(t)
+	if params != nil {
+		wrap.Append(params)
+	}
 	return wrap
 }
 
+// getTypeParams parses `[K comparable, V any]`, constraints are not kept.
+func getTypeParams(p *parser) *token {
+	params := symAtPos(p.Advance("[").Pos, "typeparams")
+	for p.Token.Symbol != "]" {
+		params.Append(p.Advance("(name)"))
+		if p.Token.Symbol == "," {
+			p.Advance(",")
+			continue
+		}
+		for depth := 0; depth > 0 || (p.Token.Symbol != "," && p.Token.Symbol != "]"); p.Next() {
+			switch p.Token.Symbol {
+			case "[", "(", "{":
+				depth++
+			case "]", ")", "}":
+				depth--
+			case "(eof)":
+				panicf("type params: unexpected eof")
+			}
+		}
+		if p.Token.Symbol == "," {
+			p.Advance(",")
+		}
+	}
+	p.Advance("]")
+	return params
+}
+
+// getTypeArgs parses `int, string]` into a "," token of types.
+func getTypeArgs(p *parser) *token {
+	args := symAtPos(p.Token.Pos, ",")
+	for p.Token.Symbol != "]" {
+		args.Append(getType(p))
+		if p.Token.Symbol != "," {
+			break
+		}
+		p.Advance(",")
+	}
+	p.Advance("]")
+	return args
+}
+
 func returnNud(p *parser, t *token) *token {
 	for p.Token.Symbol != "}" && p.Token.Symbol != ";" && p.Token.Symbol != "case" && p.Token.Symbol != "default" {
 		t.Append(p.Expression(commaBP))
@@ -153,6 +260,11 @@ func returnNud(p *parser, t *token) *token {
 		}
 		p.Advance(",")
 	}
+	if len(t.Tokens) == 0 && p.results != nil { // bare return of named results
+		for _, name := range p.results.Tokens[0].Tokens {
+			t.Append(&token{Pos: t.Pos, Symbol: "(name)", Text: name.Text})
+		}
+	}
 	if len(t.Tokens) == 1 {
 		if t.Tokens[0].Symbol == "call" {
 			t.Tokens[0].Tokens[2].Text = "-1"
@@ -270,7 +382,7 @@ func makeNud(p *parser, t *token) *token {
 	p.Advance("(")
 	typ := getType(p)
 	t.Append(typ)
-	if p.Token.Symbol == "," {
+	for p.Token.Symbol == "," && len(t.Tokens) < 3 {
 		p.Advance(",")
 		t.Append(p.Expression(commaBP))
 	}
@@ -333,7 +445,7 @@ func getType(p *parser) *token {
 		t.Append(getType(p))
 		p.Advance("]")
 		t.Append(getType(p))
-	case "any", "float64", "int", "int32", "uint32", "uint", "rune", "byte", "uint8", "int8", "uint16", "int16", "uint64", "int64", "bool", "string", "error":
+	case "any", "float32", "float64", "int", "int32", "uint32", "uint", "rune", "byte", "uint8", "int8", "uint16", "int16", "uint64", "int64", "bool", "string", "error":
 	case "(name)":
 		if p.Token.Symbol == "." {
 			p.Advance(".")
@@ -342,8 +454,19 @@ func getType(p *parser) *token {
 			t.Append(tmp)
 			t.Append(p.Advance("(name)"))
 		}
+		if p.Token.Symbol == "[" {
+			tmp := t
+			t = symAtPos(p.Advance("[").Pos, "index")
+			t.Append(tmp)
+			t.Append(getTypeArgs(p))
+		}
 	case "*":
-		t = getType(p)
+		t.Append(getType(p))
+	case "[":
+		t.rename("array")
+		t.Append(p.Expression(commaBP))
+		p.Advance("]")
+		t.Append(getType(p))
 	case "interface":
 		p.Advance("{")
 		for p.Token.Symbol != "}" {
@@ -351,22 +474,43 @@ func getType(p *parser) *token {
 				p.Advance(";")
 				continue
 			}
+			if embed := getInterfaceEmbed(p); embed != nil {
+				t.Append(embed)
+				continue
+			}
+			if p.Token.Symbol != "(name)" || p.Tokens[p.N].Symbol != "(" { // type set, e.g. ~int | ~float64
+				skipLine(p)
+				continue
+			}
 			name := p.Advance("(name)")
 			t.Append(name)
 			args, last := getArgs(p)
 			t.Append(args)
-			rets := getReturns(last, p)
+			rets, _ := getReturns(last, p)
 			t.Append(rets)
 		}
 		p.Advance("}")
 	case "func":
 		args, last := getArgs(p)
 		t.Append(args)
-		rets := getReturns(last, p)
+		rets, _ := getReturns(last, p)
 		t.Append(rets)
 	case "struct":
 		p.Advance("{")
 		for p.Token.Symbol != "}" {
+			if p.Token.Symbol == ";" { // HACK: for tests
+				p.Advance(";")
+				continue
+			}
+			if embed := getEmbed(p); embed != nil {
+				if p.Token.Symbol == "(string)" {
+					embed.Append(p.Advance("(string)"))
+				}
+				t.Append(embed)
+				t.Append(embed.Tokens[0])
+				embed.Tokens = embed.Tokens[1:]
+				continue
+			}
 			var names []*token
 			for {
 				if p.Token.Symbol == ";" { // HACK: for tests
@@ -380,7 +524,14 @@ func getType(p *parser) *token {
 				p.Advance(",")
 			}
 			typ := getType(p)
+			var tag *token
+			if p.Token.Symbol == "(string)" {
+				tag = p.Advance("(string)")
+			}
 			for _, n := range names {
+				if tag != nil {
+					n.Append(tag)
+				}
 				t.Append(n)
 				t.Append(typ)
 			}
@@ -394,6 +545,67 @@ func getType(p *parser) *token {
 	return t
 }
 
+// skipLine skips to the end of the line, following trailing "|".
+func skipLine(p *parser) {
+	line := p.Token.Pos.Line
+	for p.Token.Symbol != "}" && p.Token.Symbol != ";" {
+		prev := p.Token
+		p.Next()
+		if p.Token.Pos.Line != line {
+			if prev.Symbol != "|" {
+				break
+			}
+			line = p.Token.Pos.Line
+		}
+	}
+}
+
+// getEmbed parses an embedded field `T`, `*T` or `pkg.T` into an "embed"
+// token named after the type, holding the type.
+func getEmbed(p *parser) *token {
+	if p.Token.Symbol != "(name)" && p.Token.Symbol != "*" {
+		return nil
+	} else if next := p.Tokens[p.N]; p.Token.Symbol == "(name)" && next.Symbol != "." && next.Symbol != "}" && next.Symbol != ";" && next.Symbol != "(string)" && next.Pos.Line == p.Token.Pos.Line {
+		return nil
+	}
+	typ := getType(p)
+	name := elemType(typ)
+	embed := &token{Pos: typ.Pos, Symbol: "embed", Text: name.Text}
+	if name.Symbol == "." {
+		embed.Text = name.Tokens[1].Text
+	}
+	embed.Append(typ)
+	return embed
+}
+
+// getInterfaceEmbed parses an embedded interface, `Reader` or `io.Reader`.
+func getInterfaceEmbed(p *parser) *token {
+	n := p.N
+	if p.Token.Symbol != "(name)" {
+		return nil
+	} else if p.Tokens[n].Symbol == "." {
+		n += 2
+	}
+	if end := p.Tokens[n].Symbol; end != ";" && end != "}" {
+		return nil
+	}
+	typ := getType(p)
+	embed := &token{Pos: typ.Pos, Symbol: "embed", Text: typ.Text}
+	if typ.Symbol == "." {
+		embed.Text = typ.Tokens[1].Text
+	}
+	embed.Append(typ)
+	return embed
+}
+
+// elemType returns the type a pointer type tok points to.
+func elemType(tok *token) *token {
+	if tok.Symbol == "*" {
+		return tok.Tokens[0]
+	}
+	return tok
+}
+
 func getDecl(p *parser, kind string) *token {
 	if p.Token.Symbol == ";" { // HACK: for tests
 		p.Advance(";")
@@ -462,20 +674,28 @@ func constNud(p *parser, t *token) *token {
 		t.Append(plural(symAtPos(t.Pos, ",")))
 		t.Append(plural(symAtPos(t.Pos, ",")))
 		p.Advance("(")
-		var prev *token
+		var prev, prevType *token
 		for p.Token.Symbol != ")" {
 			if decl := getDecl(p, kind); decl != nil {
-				for _, tt := range plural(decl.Tokens[0]).Tokens {
+				names := plural(decl.Tokens[0]).Tokens
+				for _, tt := range names {
 					t.Tokens[0].Append(tt)
 				}
 				if len(decl.Tokens) > 1 {
+					prevType = nil
+					if len(names[0].Tokens) > 0 {
+						prevType = names[0].Tokens[0]
+					}
 					for _, tt := range plural(decl.Tokens[1]).Tokens {
 						prev = tt.Copy()
 						tt.Replace("iota", "(int)", fmt.Sprint(len(t.Tokens[1].Tokens)))
 						t.Tokens[1].Append(tt)
 					}
 				} else {
-					for range plural(decl.Tokens[0]).Tokens {
+					for _, name := range names {
+						if prevType != nil {
+							name.Append(prevType.Copy())
+						}
 						tt := prev.Copy()
 						tt.Replace("iota", "(int)", fmt.Sprint(len(t.Tokens[1].Tokens)))
 						t.Tokens[1].Append(tt)
@@ -544,11 +764,22 @@ func ellipsisLed(p *parser, t *token, left *token) *token {
 func indexLed(p *parser, t *token, left *token) *token {
 	t.rename("index")
 	t.Append(left)
+	if p.Token.Symbol == "[]" || p.Token.Symbol == "map" { // type arguments
+		t.Append(getTypeArgs(p))
+		return t
+	}
 	if p.Token.Symbol != ":" {
-		t.Append(p.Expression(0))
+		t.Append(p.Expression(commaBP))
 	} else {
 		t.Append(&token{Pos: p.Token.Pos, Symbol: "(int)", Text: "0"})
 	}
+	if p.Token.Symbol == "," { // type arguments
+		args := plural(t.Tokens[1])
+		p.Advance(",")
+		args.Tokens = append(args.Tokens, getTypeArgs(p).Tokens...)
+		t.Tokens[1] = args
+		return t
+	}
 	if p.Token.Symbol == ":" {
 		t.rename("slice")
 		p.Advance(":")
@@ -573,6 +804,48 @@ func negateNud(p *parser, t *token) *token {
 	return t
 }
 
+func addressNud(p *parser, t *token) *token {
+	expr := p.doExpression(130)
+	t.rename("address")
+	t.Append(expr)
+	return t
+}
+
+func derefNud(p *parser, t *token) *token {
+	expr := p.doExpression(130)
+	t.rename("deref")
+	t.Append(expr)
+	return t
+}
+
+// arrayNud parses `[N]T{...}`, a length of ... counts the items.
+func arrayNud(p *parser, t *token) *token {
+	t.rename("array")
+	if p.Token.Symbol == "..." {
+		t.Append(symAtPos(p.Advance("...").Pos, "(int)"))
+	} else {
+		t.Append(p.Expression(commaBP))
+	}
+	p.Advance("]")
+	t.Append(getType(p))
+	data := getData(p)
+	t.Append(data)
+	if t.Tokens[0].Text == "(int)" {
+		t.Tokens[0].Text = strconv.Itoa(len(data.Tokens))
+	}
+	return t
+}
+
+// branchNud parses break, continue and goto with an optional label.
+func branchNud(p *parser, t *token) *token {
+	if p.Token.Symbol == "(name)" && p.Token.Pos.Line == t.Pos.Line {
+		t.Append(p.Advance("(name)"))
+	} else if t.Symbol == "goto" {
+		panicf("goto: missing label")
+	}
+	return t
+}
+
 func complementNud(p *parser, t *token) *token {
 	expr := p.doExpression(130) // higher BP for negation
 	t.rename("complement")
@@ -594,10 +867,17 @@ func notNud(p *parser, t *token) *token {
 
 func typeNud(p *parser, t *token) *token {
 	t.Append(p.Advance("(name)"))
+	var params *token
+	if p.Token.Symbol == "[" && p.Tokens[p.N].Symbol == "(name)" && p.Tokens[p.N+1].Symbol != "]" {
+		params = getTypeParams(p)
+	}
 	if p.Token.Symbol == "=" {
 		p.Advance("=")
 	}
 	t.Append(getType(p))
+	if params != nil {
+		t.Append(params)
+	}
 	return t
 }
 
@@ -613,7 +893,7 @@ func switchNud(p *parser, t *token) *token {
 	for {
 		if p.Token.Symbol == "case" {
 			c := p.Advance("case")
-			c.Append(p.Statement())
+			c.Append(p.Expression(0))
 			p.Advance(":")
 			c.Append(getCase(p))
 			cases.Append(c)
@@ -663,12 +943,6 @@ func stackNud(p *parser, t *token) *token {
 	return tok
 }
 
-func skipNud(p *parser, t *token) *token {
-	tok := p.Token
-	p.Next()
-	return tok
-}
-
 var symbols map[string]*symbol
 
 const commaBP = 20
@@ -700,22 +974,22 @@ func init() {
 
 		"|":  {Lbp: 70, Led: ledInfix},
 		"^":  {Lbp: 80, Led: ledInfix, Nud: complementNud},
-		"&":  {Lbp: 90, Nud: skipNud, Led: ledInfix},
+		"&":  {Lbp: 90, Nud: addressNud, Led: ledInfix},
 		"<<": {Lbp: 100, Led: ledInfix},
 		">>": {Lbp: 100, Led: ledInfix},
 
 		"+": {Lbp: 110, Led: ledInfix},
 		"-": {Lbp: 110, Led: ledInfix, Nud: negateNud},
-		"*": {Lbp: 120, Nud: skipNud, Led: ledInfix},
+		"*": {Lbp: 120, Nud: derefNud, Led: ledInfix},
 		"/": {Lbp: 120, Led: ledInfix},
 		"%": {Lbp: 120, Led: ledInfix},
 
 		"++":  {Lbp: 140, Led: ledPostfix},
 		"--":  {Lbp: 140, Led: ledPostfix},
-		".":   {Lbp: 150, Led: ledInfix},
+		".":   {Lbp: 150, Led: dotLed},
 		"...": {Lbp: 150, Led: ellipsisLed},
 		"(":   {Lbp: 150, Nud: parenNud, Led: callLed},
-		"[":   {Lbp: 150, Led: indexLed},
+		"[":   {Lbp: 150, Led: indexLed, Nud: arrayNud},
 		"{":   {Lbp: 150, Led: newLed, Nud: dataNud},
 
 		"[]":      {Nud: sliceNud},
@@ -752,6 +1026,7 @@ func init() {
 		"nil":       {Nud: nudSelf},
 		"error":     {Nud: nudSelf},
 		"range":     {Nud: nudSelf},
+		"float32":   {Nud: nudSelf},
 		"float64":   {Nud: nudSelf},
 		"any":       {Nud: nudSelf},
 		"int":       {Nud: nudSelf},
@@ -768,8 +1043,9 @@ func init() {
 		"uint64":    {Nud: nudSelf},
 		"bool":      {Nud: nudSelf},
 		"string":    {Nud: nudSelf},
-		"continue":  {Nud: nudSelf},
-		"break":     {Nud: nudSelf},
+		"continue":  {Nud: branchNud},
+		"break":     {Nud: branchNud},
+		"goto":      {Nud: branchNud},
 		"struct":    {Nud: nudSelf},
 		"interface": {Nud: nudSelf},
 		"case":      {Nud: nudSelf},
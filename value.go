@@ -2,9 +2,12 @@ package goatlang
 
 import (
 	"fmt"
+	"math"
+	"reflect"
 	"strings"
+	"unicode/utf8"
 
-	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
 )
 
 /**
@@ -22,8 +25,11 @@ On pointers:
 	v.value.(*structT) is faster than v.value.(structT)
 */
 
-type Type int
+type Type int64
 
+// valueMask and interfaceMask reuse the typeNext and typeType bits. That is
+// safe as they are only set along with nillableMin, which base, isValue and
+// isInterface all test for, while typeNext and typeType never have it.
 const (
 	TypeNil          = Type(0b00000000)
 	untypedInt       = Type(0b00000001)
@@ -34,10 +40,13 @@ const (
 	TypeInt8         = Type(0b00010011)
 	TypeUint32       = Type(0b00000111)
 	TypeInt32        = Type(0b00010111)
+	TypeFloat32      = Type(0b00011011)
 	TypeFloat64      = Type(0b00011111)
 	numericBitsMask  = Type(0b00011111)
 	typeType         = Type(0b00000100) // hidden non-numeric
 	typeNext         = Type(0b00001000) // hidden non-numeric
+	valueMask        = Type(0b00001000) // struct and array values, copied on assign, see above
+	interfaceMask    = Type(0b00000100) // interface structs, checked on assign, see above
 	TypeBool         = Type(0b00100000)
 	TypeString       = Type(0b01000000)
 	TypeObject       = Type(0b01100000)
@@ -49,9 +58,15 @@ const (
 
 	typeMask  = Type(0xff)
 	typeShift = 8
+
+	namedShift     = 48 // global index of a named type
+	underlyingMask = Type(1)<<namedShift - 1
 )
 
-func newType(t Type) Value { return Value{t: typeType, num: float64(t)} }
+// newType keeps the bits of t as named types do not fit a float64 exactly.
+func newType(t Type) Value { return Value{t: typeType, num: math.Float64frombits(uint64(t))} }
+
+func (v Value) typeValue() Type { return Type(math.Float64bits(v.num)) }
 
 var typeToString = map[Type]string{
 	TypeNil:     "any",
@@ -59,6 +74,7 @@ var typeToString = map[Type]string{
 	TypeBool:    "bool",
 	TypeUint8:   "uint8",
 	TypeInt32:   "int32",
+	TypeFloat32: "float32",
 	TypeFloat64: "float64",
 	TypeUint32:  "uint32",
 	TypeInt8:    "int8",
@@ -85,6 +101,9 @@ var typeToString = map[Type]string{
 // }
 
 func (t Type) str(g *lookup) string {
+	if n := t.named(); n > 0 {
+		return g.Key(n)
+	}
 	switch t.base() {
 	case TypeSlice:
 		v := t.value()
@@ -103,15 +122,40 @@ func (t Type) str(g *lookup) string {
 }
 
 func (t Type) base() Type {
+	if t&nillableMin != 0 {
+		return t & (typeMask &^ (valueMask | interfaceMask))
+	}
 	return t & typeMask
 }
 
+// isValue reports whether t is a struct or array value rather than a pointer
+// or slice.
+func (t Type) isValue() bool {
+	return t&(nillableMin|valueMask) == nillableMin|valueMask
+}
+
+func (t Type) isInterface() bool {
+	return t&(TypeStruct|interfaceMask) == TypeStruct|interfaceMask
+}
+
+// value is the element type of a slice, or the global index of a struct.
 func (t Type) value() Type {
-	return t >> typeShift
+	if t.base() == TypeStruct {
+		return t.underlying() >> typeShift
+	}
+	return unpackElem(t.underlying() >> typeShift)
 }
 
 func (t Type) pair() (Type, Type) {
-	return (t >> typeShift) & typeMask, (t >> (typeShift * 2))
+	return (t >> typeShift) & typeMask, unpackElem(t.underlying() >> (typeShift * 2))
+}
+
+func (t Type) named() int {
+	return int(t >> namedShift)
+}
+
+func (t Type) underlying() Type {
+	return t & underlyingMask
 }
 
 func (t Type) isSafeStr() bool {
@@ -123,17 +167,48 @@ func (t Type) isSafeStr() bool {
 }
 
 func sliceType(value Type) Type {
-	return value<<typeShift | TypeSlice
+	return packElem(value)<<typeShift | TypeSlice
 }
 
 func mapType(key, value Type) Type {
-	return value<<(typeShift*2) | key<<typeShift | TypeMap
+	return packElem(value)<<(typeShift*2) | key&typeMask<<typeShift | TypeMap
+}
+
+// packElem keeps the name of a named scalar element type just above its
+// base, so the items of a []Dir still have Dir's methods. Named slices and
+// maps do not fit and decay to their underlying type.
+func packElem(t Type) Type {
+	if n := t.named(); n > 0 && t&typeMask < nillableMin {
+		return Type(n)<<typeShift | t&typeMask
+	}
+	return t.underlying()
+}
+
+func unpackElem(t Type) Type {
+	if t&typeMask < nillableMin && t>>typeShift != 0 {
+		return t>>typeShift<<namedShift | t&typeMask
+	}
+	return t
+}
+
+// namedType gives t the identity of the type declared at global idx so
+// that methods can be looked up by name. Structs already have one.
+func namedType(idx int, t Type) Type {
+	switch t.base() {
+	case TypeNil, TypeObject, TypeFunc, TypeStruct, typeType:
+		return t
+	}
+	return Type(idx)<<namedShift | t.underlying()
 }
 
 func structType(value Type) Type {
 	return value<<typeShift | TypeStruct
 }
 
+func arrayType(value Type) Type {
+	return sliceType(value) | valueMask
+}
+
 var _ Object = &Value{}
 
 type Value struct {
@@ -159,13 +234,32 @@ type Object interface {
 }
 
 func (v Value) Get(key Value) (Value, bool) {
-	if v.value != nil || v.t.base() == TypeSlice {
+	if v.value != nil {
 		return v.value.Get(key)
 	}
-	_, vt := v.t.pair()
-	return newZero(vt), false
+	switch v.t.base() {
+	case TypeMap:
+		_, vt := v.t.pair()
+		return newZero(vt), false
+	case TypeSlice, TypeString:
+		panicIndex(key.Int(), 0)
+	}
+	panicNil()
+	return Nil(), false
+}
+func (v Value) Set(key, value Value) {
+	if v.value != nil {
+		v.value.Set(key, value)
+		return
+	}
+	switch v.t.base() {
+	case TypeMap:
+		panicRuntime(ErrNilMap, ErrNilMap.Error())
+	case TypeSlice:
+		panicIndex(key.Int(), 0)
+	}
+	panicNil()
 }
-func (v Value) Set(key, value Value) { v.value.Set(key, value) }
 func (v Value) Len() int {
 	if v.value != nil {
 		return v.value.Len()
@@ -184,7 +278,7 @@ func (v Value) Append(items ...Value) Value {
 	if v.value != nil {
 		return v.value.Append(items...)
 	} else {
-		return newSlice(v.t.value(), items)
+		return makeSlice(v.t.value(), items, len(items))
 	}
 }
 func (v Value) Delete(key Value) {
@@ -192,14 +286,45 @@ func (v Value) Delete(key Value) {
 		v.value.Delete(key)
 	}
 }
+
+// clear zeroes the items of a slice or deletes the keys of a map.
+func (v Value) clear(vm *VM) {
+	switch r := v.value.(type) {
+	case *sliceT:
+		for i := range r.data {
+			r.data[i] = vm.zero(r.valueType)
+		}
+	case *stringMap:
+		r.data, r.keys = map[string]Value{}, nil
+	case compactSlice:
+		r.clear()
+	case *numericMap:
+		r.data, r.keys = map[float64]Value{}, nil
+	case *valueMap:
+		r.data, r.keys = map[mapKey][2]Value{}, nil
+	}
+}
 func (v Value) Slice(i, j int) Value {
-	if v.value != nil || i != 0 || j != 0 {
+	if v.value != nil {
 		return v.value.Slice(i, j)
 	}
-	return newSlice(v.t.value(), nil)
+	if i != 0 || j != 0 {
+		panicSlice(i, j, 0, false)
+	}
+	return makeSlice(v.t.value(), nil, 0)
+}
+func (v Value) GetAttr(key string) Value {
+	if v.value == nil {
+		panicNil()
+	}
+	return v.value.GetAttr(key)
+}
+func (v Value) SetAttr(key string, value Value) {
+	if v.value == nil {
+		panicNil()
+	}
+	v.value.SetAttr(key, value)
 }
-func (v Value) GetAttr(key string) Value        { return v.value.GetAttr(key) }
-func (v Value) SetAttr(key string, value Value) { v.value.SetAttr(key, value) }
 
 func Wrap(o Object) Value { return Value{t: TypeObject, value: o} }
 
@@ -216,14 +341,21 @@ type funcT struct {
 	Variadic     bool
 	VariadicType Type
 	Value        func(v *VM)
+	sig          []instruction // arg then return types of script funcs
+	vm           *VM           // root VM of script funcs, to call them from Go
 }
 
 func (v Value) getFunc() *funcT {
-	return v.value.(*funcT)
+	f, ok := v.value.(*funcT)
+	if !ok && v.value == nil {
+		panicNil()
+	}
+	return f
 }
 
 func Nil() Value { return Value{} }
 
+func Float32(v float32) Value { return Value{t: TypeFloat32, num: float64(v)} }
 func Float64(v float64) Value { return Value{t: TypeFloat64, num: v} }
 func Int(v int) Value         { return Value{t: TypeInt32, num: float64(int32(v))} }
 func Int32(v int32) Value     { return Value{t: TypeInt32, num: float64(v)} }
@@ -233,29 +365,33 @@ func Int8(v int8) Value       { return Value{t: TypeInt8, num: float64(v)} }
 func Byte(v byte) Value       { return Value{t: TypeUint8, num: float64(v)} }
 func Uint8(v uint8) Value     { return Value{t: TypeUint8, num: float64(v)} }
 
+func (v Value) Float32() float32 { return float32(v.num) }
 func (v Value) Float64() float64 { return v.num }
 func (v Value) Int() int         { return int(v.num) }
-func (v Value) Int32() int32     { return int32(v.num) }
-func (v Value) Uint() uint       { return uint(v.num) }
-func (v Value) Uint32() uint32   { return uint32(v.num) }
-func (v Value) Int8() int8       { return int8(v.num) }
-func (v Value) Byte() byte       { return byte(v.num) }
-func (v Value) Uint8() uint8     { return uint8(v.num) }
+func (v Value) Int32() int32     { return toInt32(v.num) }
+func (v Value) Uint() uint       { return uint(toUint32(v.num)) }
+func (v Value) Uint32() uint32   { return toUint32(v.num) }
+func (v Value) Int8() int8       { return toInt8(v.num) }
+func (v Value) Byte() byte       { return toUint8(v.num) }
+func (v Value) Uint8() uint8     { return toUint8(v.num) }
 
 type safeStr interface {
 	SafeStr() string
 }
 
 func (v Value) safeStr() string {
-	if v, ok := v.value.(safeStr); ok {
-		return v.SafeStr()
+	if o, ok := v.value.(safeStr); ok {
+		if v.t.isValue() {
+			return strings.TrimPrefix(o.SafeStr(), "&")
+		}
+		return o.SafeStr()
 	}
 	return v.String()
 }
 func newZero(t Type) Value {
-	switch t {
+	switch t.underlying() {
 	case TypeString:
-		return String("")
+		return Value{t: t, value: stringT("")}
 	default:
 		return Value{t: t}
 	}
@@ -329,6 +465,8 @@ func (v Value) String() string {
 		return fmt.Sprint(v.Bool())
 	case TypeInt32, TypeUint32, TypeInt8, TypeUint8, untypedInt:
 		return fmt.Sprint(int(v.num))
+	case TypeFloat32:
+		return fmt.Sprint(float32(v.num))
 	case TypeFloat64:
 		return fmt.Sprint(v.num)
 	case TypeString:
@@ -336,6 +474,8 @@ func (v Value) String() string {
 	case TypeStruct, TypeFunc:
 		if v.value == nil {
 			return "nil"
+		} else if v.t.isValue() {
+			return strings.TrimPrefix(fmt.Sprint(v.value), "&")
 		}
 	case TypeSlice:
 		if v.value == nil {
@@ -371,170 +511,329 @@ func (v Value) Bool() bool {
 
 func (v Value) assign(t Type) Value {
 	switch {
+	case t.isValue() || v.t.isValue() && t&typeMask != TypeStruct:
+		return v.copy(t)
 	case v.t == t:
 		return v
+	case v.t == t.underlying() && v.t != TypeNil:
+		v.t = t
+		return v
+	case t.underlying() == TypeFloat32 && v.t&isNumericMask != 0:
+		return Value{t: t, num: toFloat32(v.num)}
 	case v.t == untypedInt:
-		switch t {
+		switch t.underlying() {
 		case TypeFloat64:
 			return Value{t: t, num: v.num}
 		case TypeInt32:
-			return Value{t: t, num: float64(int32(v.num))}
+			return Value{t: t, num: float64(toInt32(v.num))}
 		case TypeUint32:
-			return Value{t: t, num: float64(uint32(v.num))}
+			return Value{t: t, num: float64(toUint32(v.num))}
 		case TypeInt8:
-			return Value{t: t, num: float64(int8(v.num))}
+			return Value{t: t, num: float64(toInt8(v.num))}
 		case TypeUint8:
-			return Value{t: t, num: float64(uint8(v.num))}
+			return Value{t: t, num: float64(toUint8(v.num))}
 		default:
-			return Value{t: TypeInt32, num: float64(int32(v.num))}
+			return Value{t: TypeInt32, num: float64(toInt32(v.num))}
 		}
 	case v.t != TypeNil:
 		return v
-	case t >= nillableMin:
+	case t.base() >= nillableMin:
 		return Value{t: t}
 	default:
 		return Value{}
 	}
 }
 
+// copy returns a copy of the struct or array v as t, or as a value of its own
+// type when t is not a value type.
+func (v Value) copy(t Type) Value {
+	switch o := v.value.(type) {
+	case *structT:
+		s := *o
+		s.Fields = o.Fields.Copy()
+		v.value = &s
+	case *sliceT:
+		data := make([]Value, len(o.data))
+		for i, e := range o.data {
+			data[i] = e.assign(o.valueType)
+		}
+		v.value = &sliceT{valueType: o.valueType, data: data}
+	default:
+		return v
+	}
+	if t.isValue() {
+		v.t = t
+	} else {
+		v.t |= valueMask
+	}
+	return v
+}
+
+// implements panics unless v has the methods of the interface t.
+func (vm *VM) implements(v Value, t Type) {
+	if name := vm.missingMethod(v, t); name != "" {
+		panicf("%v does not implement %v (missing method %v)", v.t.str(vm.globals), t.str(vm.globals), name)
+	}
+}
+
+// missingMethod returns a method of the interface t that v lacks, or "".
+func (vm *VM) missingMethod(v Value, t Type) string {
+	iface, ok := vm.globals.Read(int(t.value())).value.(*structT)
+	if !ok || v.value == nil && (v.t == TypeNil || v.t.base() >= nillableMin) {
+		return ""
+	}
+	m := vm.globals.methodSet(iface)
+	if m.ok[v.t] {
+		return ""
+	}
+	for i, name := range m.names {
+		if !v.hasMethod(vm, m.keys[i]) {
+			return name
+		}
+	}
+	if v.t.named() > 0 || v.t.base() == TypeStruct && v.t.value() > 0 { // anonymous structs and objects differ by value
+		m.ok[v.t] = true
+	}
+	return ""
+}
+
+// assert is the type assertion v.(t), ok is false when v is nil or not of
+// type t. Interfaces are checked like conversions, see implements.
+func (vm *VM) assert(v Value, t Type) (res Value, ok bool, err string) {
+	if v.t == TypeNil || v.value == nil && v.t.isInterface() {
+		return vm.zero(t), false, fmt.Sprintf("interface conversion: interface is nil, not %v", t.str(vm.globals))
+	}
+	if t.isInterface() {
+		if name := vm.missingMethod(v, t); name != "" {
+			return vm.zero(t), false, fmt.Sprintf("interface conversion: %v is not %v: missing method %v", v.t.str(vm.globals), t.str(vm.globals), name)
+		}
+		return v, true, ""
+	}
+	if vt := v.t; vt == t || vt == untypedInt && t == TypeInt32 {
+		return v.assign(t), true, ""
+	}
+	return vm.zero(t), false, fmt.Sprintf("interface conversion: interface is %v, not %v", v.t.str(vm.globals), t.str(vm.globals))
+}
+
+// hasMethod reports whether v has the method k.
+func (v Value) hasMethod(vm *VM, k int) bool {
+	if s, ok := v.value.(*structT); ok {
+		return s.hasMethod(vm, int(v.t.value()), k, !v.t.isValue())
+	}
+	if n := v.t.named(); n > 0 {
+		_, ok := vm.globals.method(n, k)
+		return ok
+	}
+	if o := v.Unwrap(); o != nil {
+		return objectHasMethod(o, vm.globals.Key(k))
+	}
+	return false
+}
+
+// objectHasMethod reports whether the host object o has the method name,
+// objects without attributes have none.
+func objectHasMethod(o Object, name string) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	return o.GetAttr(name).value != nil
+}
+
+// setDeref is `*v = b`, replacing the struct or array v points to.
+func (v Value) setDeref(b Value) {
+	switch o := v.value.(type) {
+	case *structT:
+		*o = *b.copy(b.t).value.(*structT)
+	case *sliceT:
+		o.data = b.copy(b.t).value.(*sliceT).data
+	case nil:
+		panicNil()
+	default:
+		panic("unsupported")
+	}
+}
+
+// mixType is the type of a binary operation on a and b, float32 when
+// either is one as untyped float constants are float64 at runtime.
 func mixType(a, b Type) Type {
-	return a | b
+	t := a | b
+	if t&numericBitsMask == TypeFloat64 && (a&numericBitsMask == TypeFloat32 || b&numericBitsMask == TypeFloat32) {
+		t = t&^numericBitsMask | TypeFloat32
+	}
+	return t
 }
 
+// isFloat reports whether t is a float32 or float64.
+func (t Type) isFloat() bool {
+	u := t.underlying()
+	return u == TypeFloat32 || u == TypeFloat64
+}
+
+// integer conversions go through int64 so out of range values wrap like Go
+func toInt32(f float64) int32   { return int32(int64(f)) }
+func toUint32(f float64) uint32 { return uint32(int64(f)) }
+func toInt8(f float64) int8     { return int8(int64(f)) }
+func toUint8(f float64) uint8   { return uint8(int64(f)) }
+
+// toFloat32 rounds f to the nearest float32.
+func toFloat32(f float64) float64 { return float64(float32(f)) }
+
 func (v Value) opAdd(b Value) Value {
 	t := mixType(v.t, b.t)
-	switch t {
+	switch t.underlying() {
+	case TypeFloat32:
+		return Value{t: t, num: toFloat32(toFloat32(v.num) + toFloat32(b.num))}
 	case TypeFloat64:
 		return Value{t: t, num: v.num + b.num}
 	case TypeInt32:
-		return Value{t: t, num: float64(int32(v.num) + int32(b.num))}
+		return Value{t: t, num: float64(toInt32(v.num) + toInt32(b.num))}
 	case TypeUint32:
-		return Value{t: t, num: float64(uint32(v.num) + uint32(b.num))}
+		return Value{t: t, num: float64(toUint32(v.num) + toUint32(b.num))}
 	case TypeInt8:
-		return Value{t: t, num: float64(int8(v.num) + int8(b.num))}
+		return Value{t: t, num: float64(toInt8(v.num) + toInt8(b.num))}
 	case TypeUint8:
-		return Value{t: t, num: float64(byte(v.num) + byte(b.num))}
+		return Value{t: t, num: float64(toUint8(v.num) + toUint8(b.num))}
 	case TypeString:
-		return String(string(v.value.(stringT) + b.value.(stringT)))
+		return Value{t: t, value: v.value.(stringT) + b.value.(stringT)}
 	default:
 		return Value{t: untypedInt, num: v.num + b.num}
 	}
 }
 func (v Value) opSub(b Value) Value {
 	t := mixType(v.t, b.t)
-	switch t {
+	switch t.underlying() {
+	case TypeFloat32:
+		return Value{t: t, num: toFloat32(toFloat32(v.num) - toFloat32(b.num))}
 	case TypeFloat64:
 		return Value{t: t, num: v.num - b.num}
 	case TypeInt32:
-		return Value{t: t, num: float64(int32(v.num) - int32(b.num))}
+		return Value{t: t, num: float64(toInt32(v.num) - toInt32(b.num))}
 	case TypeUint32:
-		return Value{t: t, num: float64(uint32(v.num) - uint32(b.num))}
+		return Value{t: t, num: float64(toUint32(v.num) - toUint32(b.num))}
 	case TypeInt8:
-		return Value{t: t, num: float64(int8(v.num) - int8(b.num))}
+		return Value{t: t, num: float64(toInt8(v.num) - toInt8(b.num))}
 	case TypeUint8:
-		return Value{t: t, num: float64(byte(v.num) - byte(b.num))}
+		return Value{t: t, num: float64(toUint8(v.num) - toUint8(b.num))}
 	default:
 		return Value{t: untypedInt, num: v.num - b.num}
 	}
 }
 func (v Value) opMul(b Value) Value {
 	t := mixType(v.t, b.t)
-	switch t {
+	switch t.underlying() {
+	case TypeFloat32:
+		return Value{t: t, num: toFloat32(toFloat32(v.num) * toFloat32(b.num))}
 	case TypeFloat64:
 		return Value{t: t, num: v.num * b.num}
 	case TypeInt32:
-		return Value{t: t, num: float64(int32(v.num) * int32(b.num))}
+		return Value{t: t, num: float64(toInt32(v.num) * toInt32(b.num))}
 	case TypeUint32:
-		return Value{t: t, num: float64(uint32(v.num) * uint32(b.num))}
+		return Value{t: t, num: float64(toUint32(v.num) * toUint32(b.num))}
 	case TypeInt8:
-		return Value{t: t, num: float64(int8(v.num) * int8(b.num))}
+		return Value{t: t, num: float64(toInt8(v.num) * toInt8(b.num))}
 	case TypeUint8:
-		return Value{t: t, num: float64(byte(v.num) * byte(b.num))}
+		return Value{t: t, num: float64(toUint8(v.num) * toUint8(b.num))}
 	default:
 		return Value{t: untypedInt, num: v.num * b.num}
 	}
 }
 func (v Value) opDiv(b Value) Value {
 	t := mixType(v.t, b.t)
-	switch t {
+	if b.num == 0 && !t.isFloat() {
+		panicRuntime(ErrDivideByZero, ErrDivideByZero.Error())
+	}
+	switch t.underlying() {
+	case TypeFloat32:
+		return Value{t: t, num: toFloat32(toFloat32(v.num) / toFloat32(b.num))}
 	case TypeFloat64:
 		return Value{t: t, num: v.num / b.num}
 	case TypeInt32:
-		return Value{t: t, num: float64(int32(v.num) / int32(b.num))}
+		return Value{t: t, num: float64(toInt32(v.num) / toInt32(b.num))}
 	case TypeUint32:
-		return Value{t: t, num: float64(uint32(v.num) / uint32(b.num))}
+		return Value{t: t, num: float64(toUint32(v.num) / toUint32(b.num))}
 	case TypeInt8:
-		return Value{t: t, num: float64(int8(v.num) / int8(b.num))}
+		return Value{t: t, num: float64(toInt8(v.num) / toInt8(b.num))}
 	case TypeUint8:
-		return Value{t: t, num: float64(byte(v.num) / byte(b.num))}
+		return Value{t: t, num: float64(toUint8(v.num) / toUint8(b.num))}
 	default:
 		return Value{t: untypedInt, num: float64(int(v.num) / int(b.num))}
 	}
 }
 func (v Value) opMod(b Value) Value {
 	t := mixType(v.t, b.t)
-	switch t {
-	case TypeFloat64:
+	if b.num == 0 {
+		panicRuntime(ErrDivideByZero, ErrDivideByZero.Error())
+	}
+	switch t.underlying() {
+	case TypeFloat32, TypeFloat64:
 		return Value{t: t, num: float64(int(v.num) % int(b.num))}
 	case TypeInt32:
-		return Value{t: t, num: float64(int32(v.num) % int32(b.num))}
+		return Value{t: t, num: float64(toInt32(v.num) % toInt32(b.num))}
 	case TypeUint32:
-		return Value{t: t, num: float64(uint32(v.num) % uint32(b.num))}
+		return Value{t: t, num: float64(toUint32(v.num) % toUint32(b.num))}
 	case TypeInt8:
-		return Value{t: t, num: float64(int8(v.num) % int8(b.num))}
+		return Value{t: t, num: float64(toInt8(v.num) % toInt8(b.num))}
 	case TypeUint8:
-		return Value{t: t, num: float64(byte(v.num) % byte(b.num))}
+		return Value{t: t, num: float64(toUint8(v.num) % toUint8(b.num))}
 	default:
 		return Value{t: untypedInt, num: float64(int(v.num) % int(b.num))}
 	}
 }
 func (v Value) opBitLsh(b Value) Value {
-	t := mixType(v.t, b.t)
-	switch t {
-	case TypeFloat64:
-		return Value{t: t, num: float64(int(v.num) << int(b.num))}
+	if b.num < 0 {
+		panicRuntime(ErrNegativeShift, "negative shift amount")
+	}
+	n := uint64(b.num)
+	switch v.t.underlying() {
+	case TypeFloat32, TypeFloat64:
+		return Value{t: v.t, num: float64(int(v.num) << n)}
 	case TypeInt32:
-		return Value{t: t, num: float64(int32(v.num) << int32(b.num))}
+		return Value{t: v.t, num: float64(toInt32(v.num) << n)}
 	case TypeUint32:
-		return Value{t: t, num: float64(uint32(v.num) << uint32(b.num))}
+		return Value{t: v.t, num: float64(toUint32(v.num) << n)}
 	case TypeInt8:
-		return Value{t: t, num: float64(int8(v.num) << int8(b.num))}
+		return Value{t: v.t, num: float64(toInt8(v.num) << n)}
 	case TypeUint8:
-		return Value{t: t, num: float64(byte(v.num) << byte(b.num))}
+		return Value{t: v.t, num: float64(toUint8(v.num) << n)}
 	default:
-		return Value{t: untypedInt, num: float64(int(v.num) << int(b.num))}
+		return Value{t: untypedInt, num: float64(int(v.num) << n)}
 	}
 }
 func (v Value) opBitRsh(b Value) Value {
-	t := mixType(v.t, b.t)
-	switch t {
-	case TypeFloat64:
-		return Value{t: t, num: float64(int(v.num) >> int(b.num))}
+	if b.num < 0 {
+		panicRuntime(ErrNegativeShift, "negative shift amount")
+	}
+	n := uint64(b.num)
+	switch v.t.underlying() {
+	case TypeFloat32, TypeFloat64:
+		return Value{t: v.t, num: float64(int(v.num) >> n)}
 	case TypeInt32:
-		return Value{t: t, num: float64(int32(v.num) >> int32(b.num))}
+		return Value{t: v.t, num: float64(toInt32(v.num) >> n)}
 	case TypeUint32:
-		return Value{t: t, num: float64(uint32(v.num) >> uint32(b.num))}
+		return Value{t: v.t, num: float64(toUint32(v.num) >> n)}
 	case TypeInt8:
-		return Value{t: t, num: float64(int8(v.num) >> int8(b.num))}
+		return Value{t: v.t, num: float64(toInt8(v.num) >> n)}
 	case TypeUint8:
-		return Value{t: t, num: float64(byte(v.num) >> byte(b.num))}
+		return Value{t: v.t, num: float64(toUint8(v.num) >> n)}
 	default:
-		return Value{t: untypedInt, num: float64(int(v.num) >> int(b.num))}
+		return Value{t: untypedInt, num: float64(int(v.num) >> n)}
 	}
 }
 func (v Value) opBitAnd(b Value) Value {
 	t := mixType(v.t, b.t)
-	switch t {
-	case TypeFloat64:
+	switch t.underlying() {
+	case TypeFloat32, TypeFloat64:
 		return Value{t: t, num: float64(int(v.num) & int(b.num))}
 	case TypeInt32:
-		return Value{t: t, num: float64(int32(v.num) & int32(b.num))}
+		return Value{t: t, num: float64(toInt32(v.num) & toInt32(b.num))}
 	case TypeUint32:
-		return Value{t: t, num: float64(uint32(v.num) & uint32(b.num))}
+		return Value{t: t, num: float64(toUint32(v.num) & toUint32(b.num))}
 	case TypeInt8:
-		return Value{t: t, num: float64(int8(v.num) & int8(b.num))}
+		return Value{t: t, num: float64(toInt8(v.num) & toInt8(b.num))}
 	case TypeUint8:
-		return Value{t: t, num: float64(byte(v.num) & byte(b.num))}
+		return Value{t: t, num: float64(toUint8(v.num) & toUint8(b.num))}
 	default:
 		return Value{t: untypedInt, num: float64(int(v.num) & int(b.num))}
 	}
@@ -542,65 +841,85 @@ func (v Value) opBitAnd(b Value) Value {
 
 func (v Value) opBitOr(b Value) Value {
 	t := mixType(v.t, b.t)
-	switch t {
-	case TypeFloat64:
+	switch t.underlying() {
+	case TypeFloat32, TypeFloat64:
 		return Value{t: t, num: float64(int(v.num) | int(b.num))}
 	case TypeInt32:
-		return Value{t: t, num: float64(int32(v.num) | int32(b.num))}
+		return Value{t: t, num: float64(toInt32(v.num) | toInt32(b.num))}
 	case TypeUint32:
-		return Value{t: t, num: float64(uint32(v.num) | uint32(b.num))}
+		return Value{t: t, num: float64(toUint32(v.num) | toUint32(b.num))}
 	case TypeInt8:
-		return Value{t: t, num: float64(int8(v.num) | int8(b.num))}
+		return Value{t: t, num: float64(toInt8(v.num) | toInt8(b.num))}
 	case TypeUint8:
-		return Value{t: t, num: float64(byte(v.num) | byte(b.num))}
+		return Value{t: t, num: float64(toUint8(v.num) | toUint8(b.num))}
 	default:
 		return Value{t: untypedInt, num: float64(int(v.num) | int(b.num))}
 	}
 }
 func (v Value) opBitXor(b Value) Value {
 	t := mixType(v.t, b.t)
-	switch t {
-	case TypeFloat64:
+	switch t.underlying() {
+	case TypeFloat32, TypeFloat64:
 		return Value{t: t, num: float64(int(v.num) ^ int(b.num))}
 	case TypeInt32:
-		return Value{t: t, num: float64(int32(v.num) ^ int32(b.num))}
+		return Value{t: t, num: float64(toInt32(v.num) ^ toInt32(b.num))}
 	case TypeUint32:
-		return Value{t: t, num: float64(uint32(v.num) ^ uint32(b.num))}
+		return Value{t: t, num: float64(toUint32(v.num) ^ toUint32(b.num))}
 	case TypeInt8:
-		return Value{t: t, num: float64(int8(v.num) ^ int8(b.num))}
+		return Value{t: t, num: float64(toInt8(v.num) ^ toInt8(b.num))}
 	case TypeUint8:
-		return Value{t: t, num: float64(byte(v.num) ^ byte(b.num))}
+		return Value{t: t, num: float64(toUint8(v.num) ^ toUint8(b.num))}
 	default:
 		return Value{t: untypedInt, num: float64(int(v.num) ^ int(b.num))}
 	}
 }
 
 func (v Value) opLt(b Value) Value {
-	if v.t != TypeString {
-		return Bool(v.num < b.num)
+	if v.t.base() != TypeString {
+		x, y := v.nums(b)
+		return Bool(x < y)
 	}
 	return Bool(v.value.(stringT) < b.value.(stringT))
 }
 
 func (v Value) opLte(b Value) Value {
-	if v.t != TypeString {
-		return Bool(v.num <= b.num)
+	if v.t.base() != TypeString {
+		x, y := v.nums(b)
+		return Bool(x <= y)
 	}
 	return Bool(v.value.(stringT) <= b.value.(stringT))
 }
 
+// nums returns the numbers of v and b, rounded to float32 when either is
+// one.
+func (v Value) nums(b Value) (float64, float64) {
+	if v.t.underlying() == TypeFloat32 || b.t.underlying() == TypeFloat32 {
+		return toFloat32(v.num), toFloat32(b.num)
+	}
+	return v.num, b.num
+}
+
 func (v Value) opNeq(b Value) Value { return Bool(!v.Equals(b)) }
 
 func (v Value) Equals(b Value) bool {
 	switch {
-	case v.t == TypeBool:
+	case v.t.isValue():
+		return v.equalsValue(b)
+	case v.t.base() == TypeBool:
 		return v.num == b.num
 	case (v.t & TypeFloat64) > 0:
-		return v.num == b.num
-	case v.t == TypeString:
+		x, y := v.nums(b)
+		return x == y
+	case v.t.base() == TypeString:
 		return v.value.(stringT) == b.value.(stringT)
 	case v.t.base() == TypeStruct, v.t == TypeFunc:
 		return (b.t == TypeNil && v.value == nil) || v.value == b.value
+	case v.t == TypeObject:
+		if e, ok := v.value.(*errorT); ok {
+			p, ok := b.value.(*errorT)
+			return ok && e.err == p.err
+		}
+		return v.value == b.value
 	case v.t == TypeNil && b.t == TypeNil:
 		return true
 	case v.t.base() == TypeSlice && b.t == TypeNil:
@@ -613,44 +932,94 @@ func (v Value) Equals(b Value) bool {
 }
 func (v Value) opEq(b Value) Value { return Bool(v.Equals(b)) }
 
+// equalsValue compares structs by field and arrays by element.
+func (v Value) equalsValue(b Value) bool {
+	switch o := v.value.(type) {
+	case *structT:
+		p, ok := b.value.(*structT)
+		if !ok {
+			return false
+		}
+		for _, k := range o.Order {
+			x, _ := o.Fields.Get(o.Lookup[k])
+			y, _ := p.Fields.Get(o.Lookup[k])
+			if !x.Equals(y) {
+				return false
+			}
+		}
+		return true
+	case *sliceT:
+		p, ok := b.value.(*sliceT)
+		if !ok || len(o.data) != len(p.data) {
+			return false
+		}
+		for i := range o.data {
+			if !o.data[i].Equals(p.data[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return v.value == b.value
+}
+
 func (v Value) convert(t Type) (res Value) {
+	if t.named() > 0 {
+		res = v.convert(t.underlying())
+		res.t = t
+		return res
+	}
 	switch t {
 	case TypeUint8:
-		return Uint8(uint8(int64(v.num)))
+		return Uint8(toUint8(v.num))
 	case TypeInt8:
-		return Int8(int8(int64(v.num)))
+		return Int8(toInt8(v.num))
 	case TypeInt32:
-		if v.t == TypeFloat64 {
+		if v.t.isFloat() {
 			return Int32(int32(v.num))
 		}
-		return Int32(int32(int64(v.num)))
+		return Int32(toInt32(v.num))
 	case TypeUint32:
-		return Uint32(uint32(v.num))
+		return Uint32(toUint32(v.num))
+	case TypeFloat32:
+		return Float32(float32(v.num))
 	case TypeFloat64:
 		return Float64(v.num)
 	case TypeString:
-		if v.t == TypeString {
-			return v
+		if v.t.base() == TypeString {
+			return String(v.String())
 		} else if v.t&isNumericMask != 0 {
 			return String(string(rune(v.num)))
 		}
+		switch s := v.value.(type) {
+		case *typedSlice[uint8, uint8Codec]:
+			return String(string(s.data))
+		case *typedSlice[int32, int32Codec]:
+			return String(string(s.data))
+		}
 		data := v.data()
 		b := make([]byte, len(data))
 		for k, v := range data {
 			b[k] = byte(v.num)
 		}
 		return String(string(b))
-	case TypeSlice:
+	case TypeSlice, sliceType(TypeUint8):
 		if v.t.base() == TypeSlice {
 			return v
 		}
-		data := []byte(v.String())
-		s := make([]Value, len(data))
-		for k, v := range data {
-			s[k] = Byte(v)
+		return Bytes([]byte(v.String()))
+	case sliceType(TypeInt32):
+		if v.t.base() == TypeSlice {
+			return v
 		}
-		return newSlice(TypeUint8, s)
+		return Int32s([]rune(v.String()))
 	default:
+		if t.base() == v.t.base() && t.base() >= nillableMin {
+			if t.isValue() {
+				v.t = t
+			}
+			return v
+		}
 		return Value{}
 	}
 }
@@ -665,6 +1034,14 @@ func (v Value) IsNil() bool {
 	return false
 }
 
+// update writes back items read with data, which shares them unless v is
+// a compact slice.
+func (v Value) update(data []Value) {
+	if s, ok := v.value.(compactSlice); ok {
+		s.update(data)
+	}
+}
+
 func (v Value) data() []Value {
 	if t, ok := v.value.(*sliceT); ok {
 		return t.data
@@ -683,17 +1060,35 @@ func (v Value) data() []Value {
 
 func (v Value) getIndex(vm *VM, idx int) Value {
 	if t, ok := v.value.(*structT); ok {
-		return t.GetIndex(idx)
+		r, ok := t.getIndex(idx)
+		if !ok {
+			panicf("%v has no field or method %v", v.t.str(vm.globals), vm.globals.Key(idx))
+		}
+		return r
+	}
+	if n := v.t.named(); n > 0 {
+		return v.method(vm, n, idx)
 	}
-	return v.value.GetAttr(vm.globals.Key(idx))
+	return v.GetAttr(vm.globals.Key(idx))
+}
+
+// method binds v to the method k of its named type n.
+func (v Value) method(vm *VM, n, k int) Value {
+	idx, ok := vm.globals.method(n, k)
+	if !ok {
+		panicf("%v has no field or method %v", vm.globals.Key(n), vm.globals.Key(k))
+	}
+	return newMethod(v, vm.globals.Read(idx).getFunc())
 }
 
 func (v Value) setIndex(vm *VM, idx int, val Value) {
 	if t, ok := v.value.(*structT); ok {
-		t.SetIndex(idx, val)
+		if !t.setIndex(idx, val) {
+			panicf("%v has no field %v", v.t.str(vm.globals), vm.globals.Key(idx))
+		}
 		return
 	}
-	v.value.SetAttr(vm.globals.Key(idx), val)
+	v.SetAttr(vm.globals.Key(idx), val)
 }
 
 type stringT string
@@ -702,39 +1097,55 @@ func String(v string) Value {
 	return Value{t: TypeString, value: stringT(v)}
 }
 
-func (s stringT) Get(a Value) (Value, bool) { return Int(int(s[a.Int()])), true }
-func (s stringT) Set(k, v Value)            { panic("unsupported") }
-func (s stringT) Len() int                  { return len(s) }
-func (s stringT) Range() func() (Value, Value, bool) {
-	var r []rune
-	for _, v := range s {
-		r = append(r, v)
+func (s stringT) Get(a Value) (Value, bool) {
+	i := a.Int()
+	if uint(i) >= uint(len(s)) {
+		panicIndex(i, len(s))
 	}
+	return Byte(s[i]), true
+}
+func (s stringT) Set(k, v Value) { panic("cannot assign to string index") }
+func (s stringT) Len() int       { return len(s) }
+func (s stringT) Range() func() (Value, Value, bool) {
 	n := 0
 	return func() (Value, Value, bool) {
-		if n >= len(r) {
+		if n >= len(s) {
 			return Nil(), Nil(), false
 		}
-		k, v := Int(n), r[n]
-		n++
-		return k, Int32(v), true
+		r, size := utf8.DecodeRuneInString(string(s[n:]))
+		k := Int(n)
+		n += size
+		return k, Int32(r), true
 	}
 }
 func (s stringT) Append(items ...Value) Value { panic("unsupported") }
 func (s stringT) Delete(k Value)              { panic("unsupported") }
-func (s stringT) Slice(i, j int) Value        { return String(string(s[i:j])) }
-func (s stringT) GetAttr(k string) Value      { panic("unsupported") }
-func (s stringT) SetAttr(k string, v Value)   { panic("unsupported") }
+func (s stringT) Slice(i, j int) Value {
+	if i < 0 || i > j || j > len(s) {
+		panicSlice(i, j, len(s), true)
+	}
+	return String(string(s[i:j]))
+}
+func (s stringT) GetAttr(k string) Value    { panic("unsupported") }
+func (s stringT) SetAttr(k string, v Value) { panic("unsupported") }
 
 type nextT struct {
 	Object
 	next func() (Value, Value, bool)
+	stop func()
 }
 
 func (v Value) next() (Value, Value, bool) {
 	return v.value.(*nextT).next()
 }
 
+// stop abandons an unfinished range-over-func iterator.
+func (v Value) stop() {
+	if n, ok := v.value.(*nextT); ok && n.stop != nil {
+		n.stop()
+	}
+}
+
 type sliceT struct {
 	Object
 	valueType Type
@@ -744,15 +1155,26 @@ type sliceT struct {
 func (s *sliceT) Len() int { return len(s.data) }
 
 func (s *sliceT) Get(k Value) (Value, bool) {
-	return s.data[k.Int()], true
+	i := k.Int()
+	if uint(i) >= uint(len(s.data)) {
+		panicIndex(i, len(s.data))
+	}
+	return s.data[i], true
 }
 
 func (s *sliceT) Slice(i, j int) Value {
+	if i < 0 || i > j || j > cap(s.data) {
+		panicSlice(i, j, cap(s.data), false)
+	}
 	return newSlice(s.valueType, s.data[i:j])
 }
 
 func (s *sliceT) Set(k, v Value) {
-	s.data[k.Int()] = v.assign(s.valueType)
+	i := k.Int()
+	if uint(i) >= uint(len(s.data)) {
+		panicIndex(i, len(s.data))
+	}
+	s.data[i] = v.assign(s.valueType)
 }
 
 func (s *sliceT) Range() func() (Value, Value, bool) {
@@ -769,7 +1191,11 @@ func (s *sliceT) Range() func() (Value, Value, bool) {
 }
 
 func (s *sliceT) Append(items ...Value) Value {
-	return NewSlice(s.valueType, append(s.data, items...))
+	data := append(s.data, items...)
+	for i := len(s.data); i < len(data); i++ {
+		data[i] = data[i].assign(s.valueType)
+	}
+	return newSlice(s.valueType, data)
 }
 
 func (s *sliceT) String() string {
@@ -795,7 +1221,7 @@ func NewSlice(valueType Type, data []Value) Value {
 	for i, v := range data {
 		data[i] = v.assign(valueType)
 	}
-	return newSlice(valueType, data)
+	return makeSlice(valueType, data, len(data))
 }
 
 func newSlice(valueType Type, data []Value) Value {
@@ -803,10 +1229,13 @@ func newSlice(valueType Type, data []Value) Value {
 }
 
 func NewMap(keyType, valueType Type, in []Value) Value {
-	if keyType == TypeString {
+	switch {
+	case keyType == TypeString:
 		return newStringMap(keyType, valueType, in)
+	case keyType&isNumericMask != 0, keyType.base() == TypeBool:
+		return newNumericMap(keyType, valueType, in)
 	}
-	return newNumericMap(keyType, valueType, in)
+	return newValueMap(keyType, valueType, in)
 }
 
 func newStringMap(keyType, valueType Type, in []Value) Value {
@@ -820,6 +1249,85 @@ func newStringMap(keyType, valueType Type, in []Value) Value {
 	return Value{t: mapType(keyType, valueType), value: m}
 }
 
+// mapT is implemented by the map objects, items are the entries in the
+// order their keys were inserted.
+type mapT interface {
+	Object
+	items() [][2]Value
+}
+
+// liveKeys drops deleted keys from keys, a key deleted and inserted again
+// keeps its last position.
+func liveKeys[K comparable, V any](keys []K, data map[K]V) []K {
+	res := make([]K, 0, len(data))
+	seen := make(map[K]bool, len(data))
+	for i := len(keys) - 1; i >= 0; i-- {
+		if _, ok := data[keys[i]]; ok && !seen[keys[i]] {
+			seen[keys[i]] = true
+			res = append(res, keys[i])
+		}
+	}
+	for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+		res[i], res[j] = res[j], res[i]
+	}
+	return res
+}
+
+// mapString formats m with sorted keys like fmt.
+func mapString(m mapT, safe bool) string {
+	var p []string
+	for _, item := range sortItems(m.items()) {
+		if safe && !item[1].t.isSafeStr() {
+			return "map[...]"
+		}
+		p = append(p, item[0].String()+":"+item[1].safeStr())
+	}
+	return "map[" + strings.Join(p, " ") + "]"
+}
+
+func sortItems(items [][2]Value) [][2]Value {
+	slices.SortFunc(items, func(a, b [2]Value) bool { return keyLess(a[0], b[0]) })
+	return items
+}
+
+// keyLess orders numbers and strings by value, false before true, and any
+// other keys by type then text.
+func keyLess(a, b Value) bool {
+	switch {
+	case a.t != b.t:
+		return a.t < b.t
+	case a.t.base() == TypeString:
+		return a.value.(stringT) < b.value.(stringT)
+	case a.value == nil:
+		return a.num < b.num
+	}
+	return a.String() < b.String()
+}
+
+// mapRange iterates the map a in the VM's MapOrder, skipping entries
+// deleted before they are reached.
+func (v *VM) mapRange(a Value) func() (Value, Value, bool) {
+	m, ok := a.value.(mapT)
+	if !ok || v.order == MapOrderDefault {
+		return a.Range()
+	}
+	items := m.items()
+	if v.order == MapOrderSorted {
+		sortItems(items)
+	}
+	n := 0
+	return func() (Value, Value, bool) {
+		for n < len(items) {
+			item := items[n]
+			n++
+			if v, ok := m.Get(item[0]); ok {
+				return item[0], v, true
+			}
+		}
+		return Nil(), Nil(), false
+	}
+}
+
 type stringMap struct {
 	Object
 	valueType Type
@@ -850,10 +1358,13 @@ func (m *stringMap) Delete(k Value) {
 	if len(m.data) >= (len(m.keys) >> 1) {
 		return
 	}
-	m.keys = maps.Keys(m.data)
+	m.keys = liveKeys(m.keys, m.data)
 }
 
 func (m *stringMap) Range() func() (Value, Value, bool) {
+	if len(m.keys) > len(m.data) {
+		m.keys = liveKeys(m.keys, m.data)
+	}
 	r := m.keys
 	n := 0
 	return func() (Value, Value, bool) {
@@ -869,24 +1380,16 @@ func (m *stringMap) Range() func() (Value, Value, bool) {
 	}
 }
 
-func (m *stringMap) String() string {
-	var p []string
-	for k, v := range m.data {
-		p = append(p, k+":"+v.safeStr())
+func (m *stringMap) items() [][2]Value {
+	var res [][2]Value
+	for _, k := range liveKeys(m.keys, m.data) {
+		res = append(res, [2]Value{String(k), m.data[k]})
 	}
-	return "map[" + strings.Join(p, " ") + "]"
+	return res
 }
 
-func (m *stringMap) SafeStr() string {
-	var p []string
-	for k, v := range m.data {
-		if !v.t.isSafeStr() {
-			return "map[...]"
-		}
-		p = append(p, k+":"+v.safeStr())
-	}
-	return "map[" + strings.Join(p, " ") + "]"
-}
+func (m *stringMap) String() string  { return mapString(m, false) }
+func (m *stringMap) SafeStr() string { return mapString(m, true) }
 
 type numericMap struct {
 	Object
@@ -900,17 +1403,25 @@ func newNumericMap(keyType, valueType Type, in []Value) Value {
 	m := &numericMap{keyType: keyType, valueType: valueType, data: map[float64]Value{}}
 	m.keys = make([]float64, len(in)/2)
 	for i := 0; i < len(in); i += 2 {
-		k, v := in[i].num, in[i+1]
+		k, v := numericKey(keyType, in[i]), in[i+1]
 		m.keys[i/2] = k
 		m.data[k] = v.assign(valueType)
 	}
 	return Value{t: mapType(keyType, valueType), value: m}
 }
 
+// numericKey is the map key of k in a map keyed by t.
+func numericKey(t Type, k Value) float64 {
+	if t.underlying() == TypeFloat32 {
+		return toFloat32(k.num)
+	}
+	return k.num
+}
+
 func (m *numericMap) Len() int { return len(m.data) }
 
 func (m *numericMap) Get(k Value) (Value, bool) {
-	v, ok := m.data[k.num]
+	v, ok := m.data[numericKey(m.keyType, k)]
 	if !ok {
 		return newZero(m.valueType), false
 	}
@@ -918,7 +1429,7 @@ func (m *numericMap) Get(k Value) (Value, bool) {
 }
 
 func (m *numericMap) Set(k, v Value) {
-	key := k.num
+	key := numericKey(m.keyType, k)
 	if _, ok := m.data[key]; !ok {
 		m.keys = append(m.keys, key)
 	}
@@ -926,14 +1437,17 @@ func (m *numericMap) Set(k, v Value) {
 }
 
 func (m *numericMap) Delete(k Value) {
-	delete(m.data, k.num)
+	delete(m.data, numericKey(m.keyType, k))
 	if len(m.data) >= (len(m.keys) >> 1) {
 		return
 	}
-	m.keys = maps.Keys(m.data)
+	m.keys = liveKeys(m.keys, m.data)
 }
 
 func (m *numericMap) Range() func() (Value, Value, bool) {
+	if len(m.keys) > len(m.data) {
+		m.keys = liveKeys(m.keys, m.data)
+	}
 	r := m.keys
 	n := 0
 	return func() (Value, Value, bool) {
@@ -949,38 +1463,179 @@ func (m *numericMap) Range() func() (Value, Value, bool) {
 	}
 }
 
-func (m *numericMap) String() string {
-	var p []string
-	for k, v := range m.data {
-		p = append(p, Value{t: m.keyType, num: k}.String()+":"+v.safeStr())
+func (m *numericMap) items() [][2]Value {
+	var res [][2]Value
+	for _, k := range liveKeys(m.keys, m.data) {
+		res = append(res, [2]Value{{t: m.keyType, num: k}, m.data[k]})
 	}
-	return "map[" + strings.Join(p, " ") + "]"
+	return res
 }
 
-func (m *numericMap) SafeStr() string {
-	var p []string
-	for k, v := range m.data {
-		if !v.t.isSafeStr() {
-			return "map[...]"
+func (m *numericMap) String() string  { return mapString(m, false) }
+func (m *numericMap) SafeStr() string { return mapString(m, true) }
+
+// mapKey is a comparable form of a Value, equal for keys that Equals
+// treats as equal. Struct and array values are keyed by their items, see
+// mapKeys.
+type mapKey struct {
+	t   Type
+	num float64
+	ref any
+}
+
+func newMapKey(v Value) mapKey {
+	t := v.t
+	if t == untypedInt {
+		t = TypeInt32
+	}
+	switch o := v.value.(type) {
+	case nil:
+		return mapKey{t: t, num: v.num}
+	case stringT:
+		return mapKey{t: t, ref: o}
+	}
+	if !t.isValue() {
+		return mapKey{t: t, ref: v.value}
+	}
+	var buf [8]mapKey
+	items := buf[:0]
+	switch o := v.value.(type) {
+	case *structT:
+		for _, k := range o.Order {
+			f, _ := o.Fields.Get(o.Lookup[k])
+			items = append(items, newMapKey(f))
+		}
+	case *sliceT:
+		for _, e := range o.data {
+			items = append(items, newMapKey(e))
 		}
-		p = append(p, Value{t: m.keyType, num: k}.String()+":"+v.safeStr())
 	}
-	return "map[" + strings.Join(p, " ") + "]"
+	return mapKey{t: t, num: float64(len(items)), ref: newMapKeys(items)}
+}
+
+// mapKeys is the items of a struct or array key, four at a time so keys
+// compare by value. The float items make -0 equal 0 as in Go.
+type mapKeys struct {
+	items [4]mapKey
+	more  any
+}
+
+func newMapKeys(items []mapKey) any {
+	var more any
+	for i := (len(items) - 1) / 4 * 4; i >= 0; i -= 4 {
+		k := mapKeys{more: more}
+		copy(k.items[:], items[i:])
+		more = k
+	}
+	return more
 }
 
+// valueMap holds keys that are not strings or numbers: struct and array
+// values, pointers and interfaces.
+type valueMap struct {
+	Object
+	keyType   Type
+	valueType Type
+	data      map[mapKey][2]Value // key, value
+	keys      []mapKey
+}
+
+func newValueMap(keyType, valueType Type, in []Value) Value {
+	m := &valueMap{keyType: keyType, valueType: valueType, data: map[mapKey][2]Value{}}
+	for i := 0; i < len(in); i += 2 {
+		m.Set(in[i], in[i+1])
+	}
+	return Value{t: mapType(keyType, valueType), value: m}
+}
+
+// key drops the dynamic type unless the map is keyed by an interface.
+func (m *valueMap) key(k Value) mapKey {
+	key := newMapKey(k)
+	if m.keyType != TypeNil && !m.keyType.isInterface() {
+		key.t = 0
+	}
+	return key
+}
+
+func (m *valueMap) Len() int { return len(m.data) }
+
+func (m *valueMap) Get(k Value) (Value, bool) {
+	item, ok := m.data[m.key(k)]
+	if !ok {
+		return newZero(m.valueType), false
+	}
+	return item[1], true
+}
+
+func (m *valueMap) Set(k, v Value) {
+	if !k.t.isValue() {
+		k = k.assign(m.keyType)
+	}
+	key := m.key(k)
+	if item, ok := m.data[key]; ok {
+		k = item[0]
+	} else {
+		if k.t.isValue() {
+			k = k.copy(k.t)
+		}
+		m.keys = append(m.keys, key)
+	}
+	m.data[key] = [2]Value{k, v.assign(m.valueType)}
+}
+
+func (m *valueMap) Delete(k Value) {
+	delete(m.data, m.key(k))
+	if len(m.data) >= (len(m.keys) >> 1) {
+		return
+	}
+	m.keys = liveKeys(m.keys, m.data)
+}
+
+func (m *valueMap) Range() func() (Value, Value, bool) {
+	if len(m.keys) > len(m.data) {
+		m.keys = liveKeys(m.keys, m.data)
+	}
+	r := m.keys
+	n := 0
+	return func() (Value, Value, bool) {
+		for n < len(r) {
+			item, ok := m.data[r[n]]
+			n++
+			if ok {
+				return item[0], item[1], true
+			}
+		}
+		return Nil(), Nil(), false
+	}
+}
+
+func (m *valueMap) items() [][2]Value {
+	var res [][2]Value
+	for _, k := range liveKeys(m.keys, m.data) {
+		res = append(res, m.data[k])
+	}
+	return res
+}
+
+func (m *valueMap) String() string  { return mapString(m, false) }
+func (m *valueMap) SafeStr() string { return mapString(m, true) }
+
 type structT struct {
 	Object
 	TypeN   int
 	Lookup  map[string]int
 	Order   []string
+	Tags    map[string]string
 	Fields  intMap
 	Methods *intMap
+	Embeds  []int
+	Values  []int // fields holding struct values, see allocValues
 }
 
 func NewStruct(base Value, data []Value) Value {
 	b := base.value.(*structT)
-	lookup, order, fields, methods := b.Lookup, b.Order, b.Fields.Copy(), b.Methods
-	s := newStruct(b.TypeN, lookup, order, fields, methods)
+	lookup, order, tags, fields, methods := b.Lookup, b.Order, b.Tags, b.Fields.Copy(), b.Methods
+	s := newStruct(b.TypeN, lookup, order, tags, fields, methods, b.Embeds)
 	st := s.value.(*structT)
 	for n := 0; n < len(data); n += 2 {
 		st.SetAttr(data[n].String(), data[n+1])
@@ -990,8 +1645,8 @@ func NewStruct(base Value, data []Value) Value {
 
 func newStructByIndex(base Value, data []Value) Value {
 	b := base.value.(*structT)
-	lookup, order, fields, methods := b.Lookup, b.Order, b.Fields.Copy(), b.Methods
-	s := newStruct(b.TypeN, lookup, order, fields, methods)
+	lookup, order, tags, fields, methods := b.Lookup, b.Order, b.Tags, b.Fields.Copy(), b.Methods
+	s := newStruct(b.TypeN, lookup, order, tags, fields, methods, b.Embeds)
 	st := s.value.(*structT)
 	for n := 0; n < len(data); n += 2 {
 		st.SetIndex(data[n].Int(), data[n+1])
@@ -999,24 +1654,169 @@ func newStructByIndex(base Value, data []Value) Value {
 	return s
 }
 
-func newStruct(typeN int, lookup map[string]int, order []string, data intMap, methods *intMap) Value {
-	return Value{t: TypeStruct | Type(typeN<<8), value: &structT{Lookup: lookup, Order: order, Fields: data, Methods: methods}}
+// allocValues gives the prototype s the struct values its fields could not
+// get when their type was declared later, instances copy them.
+func (v *VM) allocValues(s Value) {
+	st := s.value.(*structT)
+	for _, idx := range st.Values {
+		if f, _ := st.Fields.Get(idx); f.value == nil {
+			st.Fields.Set(idx, v.zero(f.t))
+		}
+	}
 }
 
+// zero is newZero with a new struct for struct values.
+func (v *VM) zero(t Type) Value {
+	if !t.isValue() || t.base() != TypeStruct {
+		return newZero(t)
+	}
+	base := v.globals.Read(int(t.value()))
+	if _, ok := base.value.(*structT); !ok {
+		return newZero(t)
+	}
+	v.allocValues(base)
+	s := newStructByIndex(base, nil)
+	s.t = t
+	return s
+}
+
+func newStruct(typeN int, lookup map[string]int, order []string, tags map[string]string, data intMap, methods *intMap, embeds []int) Value {
+	return Value{t: TypeStruct | Type(typeN<<8), value: &structT{Lookup: lookup, Order: order, Tags: tags, Fields: data, Methods: methods, Embeds: embeds}}
+}
+
+// FieldNames returns the field names of a struct in declaration order.
+func (v Value) FieldNames() []string {
+	s, ok := v.value.(*structT)
+	if !ok {
+		return nil
+	}
+	return append([]string(nil), s.Order...)
+}
+
+// FieldTags returns the tags of a struct keyed by field name, fields without
+// a tag are omitted.
+func (v Value) FieldTags() map[string]reflect.StructTag {
+	s, ok := v.value.(*structT)
+	if !ok {
+		return nil
+	}
+	res := make(map[string]reflect.StructTag, len(s.Tags))
+	for k, tag := range s.Tags {
+		res[k] = reflect.StructTag(tag)
+	}
+	return res
+}
+
+// GetAttr returns the field or method k, a missing one is the zero Value.
 func (s *structT) GetAttr(k string) Value {
-	return s.GetIndex(s.Lookup[k])
+	idx, ok := s.index(k)
+	if !ok {
+		return Value{}
+	}
+	return s.GetIndex(idx)
 }
 func (s *structT) SetAttr(k string, v Value) {
-	s.SetIndex(s.Lookup[k], v)
+	idx, ok := s.index(k)
+	if !ok || !s.setIndex(idx, v) {
+		panicf("struct has no field %v", k)
+	}
 }
 
 func (s *structT) GetIndex(k int) Value {
+	v, _ := s.getIndex(k)
+	return v
+}
+
+// getIndex returns the field or method k, false when s has neither.
+func (s *structT) getIndex(k int) (Value, bool) {
 	v, ok := s.Fields.Get(k)
 	if ok {
-		return v
+		return v, true
 	}
-	raw, _ := s.Methods.Get(k)
-	return newMethod(Value{t: TypeStruct, value: s}, raw.getFunc())
+	raw, ok := s.Methods.Get(k)
+	if !ok {
+		if e := s.promoted(k); e != nil {
+			return e.getIndex(k)
+		}
+		return Value{}, false
+	}
+	return newMethod(Value{t: TypeStruct, value: s}, raw.getFunc()), true
+}
+
+// index returns the index of field or method k, including promoted ones.
+func (s *structT) index(k string) (int, bool) {
+	idx, ok := s.Lookup[k]
+	if ok {
+		return idx, true
+	}
+	for _, e := range s.Embeds {
+		if es, ok := s.embedded(e); ok {
+			if idx, ok := es.index(k); ok {
+				return idx, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func (s *structT) embedded(idx int) (*structT, bool) {
+	v, _ := s.Fields.Get(idx)
+	es, ok := v.value.(*structT)
+	return es, ok
+}
+
+// method returns the method named k, including promoted ones.
+func (s *structT) method(k string) *funcT {
+	idx, ok := s.index(k)
+	if !ok {
+		return nil
+	}
+	if m, ok := s.Methods.Get(idx); ok {
+		return m.getFunc()
+	}
+	if p := s.promoted(idx); p != nil {
+		return p.method(k)
+	}
+	return nil
+}
+
+// hasMethod reports whether the struct n has the method k, including
+// promoted ones. addr is set for a pointer, which methods with a pointer
+// receiver need.
+func (s *structT) hasMethod(vm *VM, n, k int, addr bool) bool {
+	if _, ok := s.Methods.Get(k); ok {
+		return addr || !vm.globals.ptrMethod(n, k)
+	}
+	if _, ok := s.Fields.Get(k); ok {
+		return false
+	}
+	for _, e := range s.Embeds {
+		f, _ := s.Fields.Get(e)
+		if es, ok := f.value.(*structT); ok && es.hasMethod(vm, int(f.t.value()), k, addr || !f.t.isValue()) {
+			return true
+		}
+	}
+	return false
+}
+
+// promoted returns the embedded struct holding field or method k.
+func (s *structT) promoted(k int) *structT {
+	for _, e := range s.Embeds {
+		es, ok := s.embedded(e)
+		if !ok {
+			continue
+		}
+		if _, ok := es.Fields.Get(k); ok {
+			return es
+		}
+		if _, ok := es.Methods.Get(k); ok {
+			return es
+		}
+		if p := es.promoted(k); p != nil {
+			return p
+		}
+	}
+	return nil
 }
 
 func (s *structT) String() string {
@@ -1058,8 +1858,17 @@ func newMethod(obj Value, f *funcT) Value {
 	})
 }
 
-func (s *structT) SetIndex(k int, v Value) {
-	s.Fields.Assign(k, v)
+func (s *structT) SetIndex(k int, v Value) { s.setIndex(k, v) }
+
+// setIndex sets the field k, false when s has no such field.
+func (s *structT) setIndex(k int, v Value) bool {
+	if s.Fields.Assign(k, v) {
+		return true
+	}
+	if e := s.promoted(k); e != nil {
+		return e.setIndex(k, v)
+	}
+	return false
 }
 
 func nilRange() func() (Value, Value, bool) {
@@ -1068,6 +1877,21 @@ func nilRange() func() (Value, Value, bool) {
 	}
 }
 
+// intRange counts from 0 to n-1 for `for i := range n`.
+func intRange(n Value) func() (Value, Value, bool) {
+	t, i := n.t, 0
+	if t == untypedInt {
+		t = TypeInt32
+	}
+	return func() (Value, Value, bool) {
+		if i >= n.Int() {
+			return Nil(), Nil(), false
+		}
+		i++
+		return Value{t: t, num: float64(i - 1)}, Nil(), true
+	}
+}
+
 func newNext(next func() (Value, Value, bool)) Value {
 	return Value{t: typeNext, value: &nextT{next: next}}
 }
@@ -1086,6 +1910,15 @@ func (v Value) syncFields(b Value) {
 		value, _ := cur.Fields.Get(idx)
 		v.addField(key, idx, value)
 	}
+	v.value.(*structT).Embeds = cur.Embeds
+	v.value.(*structT).Values = cur.Values
+	tags := v.value.(*structT).Tags
+	for key := range tags {
+		delete(tags, key)
+	}
+	for key, tag := range cur.Tags {
+		tags[key] = tag
+	}
 }
 
 func (v Value) addMethod(key string, idx int, val Value) {
@@ -37,6 +37,16 @@ func Test_Value_Type(t *testing.T) {
 	assert(t, "res", res, TypeInt32)
 }
 
+func Test_Type_value(t *testing.T) {
+	dir := namedType(600, TypeInt32)
+	assert(t, "struct", structType(600).value(), Type(600))
+	assert(t, "interface", (structType(600) | interfaceMask).value(), Type(600))
+	assert(t, "slice", sliceType(dir).value(), dir)
+	assert(t, "sliceStruct", sliceType(structType(600)).value(), structType(600))
+	_, v := mapType(TypeString, dir).pair()
+	assert(t, "map", v, dir)
+}
+
 func Test_Struct(t *testing.T) {
 	t.Run("GetAttr", func(t *testing.T) {
 		vm := New()
@@ -63,6 +73,31 @@ func Test_Struct(t *testing.T) {
 		assert(t, "res", res, 42)
 	})
 
+	t.Run("FieldTags", func(t *testing.T) {
+		vm := New()
+		if _, err := vm.Eval(nil, "test", "package main; type T struct { ID int `json:\"id\"`; Name string `json:\"name,omitempty\"`; X int }; t := &T{}"); err != nil {
+			t.Fatalf("Eval error: %v", err)
+		}
+		val := vm.Get("main.t")
+		tags := val.FieldTags()
+		assert(t, "len", len(tags), 2)
+		assert(t, "ID", tags["ID"].Get("json"), "id")
+		assert(t, "Name", tags["Name"].Get("json"), "name,omitempty")
+		assert(t, "names", strings.Join(val.FieldNames(), " "), "ID Name X")
+	})
+
+	t.Run("FieldTags_reload", func(t *testing.T) {
+		vm := New()
+		if _, err := vm.Eval(nil, "test", "package main; type T struct { ID int `json:\"id\"` }; t := &T{}"); err != nil {
+			t.Fatalf("Eval error: %v", err)
+		}
+		if _, err := vm.Eval(nil, "test", "package main; type T struct { ID int `json:\"key\"` }"); err != nil {
+			t.Fatalf("Eval#2 error: %v", err)
+		}
+		res := vm.Get("main.t").FieldTags()["ID"].Get("json")
+		assert(t, "res", res, "key")
+	})
+
 	t.Run("New", func(t *testing.T) {
 		vm := New()
 		if _, err := vm.Eval(nil, "test", "package main; type T struct { X int }; t := &T{X:0}"); err != nil {
@@ -297,6 +332,41 @@ func (t *testSlice) Range() func() (Value, Value, bool) {
 	}
 }
 
+func Test_CompactSlice(t *testing.T) {
+	t.Run("Bytes", func(t *testing.T) {
+		b := []byte{1, 2}
+		x := Bytes(b)
+		x.Set(Int(0), Int(42))
+		assert(t, "b", fmt.Sprint(b), "[42 2]")
+		assert(t, "shared", &x.Bytes()[0] == &b[0], true)
+	})
+
+	t.Run("Float64s", func(t *testing.T) {
+		x := NewSlice(TypeFloat64, []Value{Float64(1.5), Int(2)})
+		x = x.Append(Float64(3))
+		assert(t, "res", fmt.Sprint(x.Float64s()), "[1.5 2 3]")
+	})
+
+	t.Run("Float32s", func(t *testing.T) {
+		f := []float32{0.5}
+		x := Float32s(f).Append(Float64(0.1))
+		assert(t, "res", fmt.Sprint(x.Float32s()), "[0.5 0.1]")
+		item, _ := x.Get(Int(1))
+		assert(t, "item", item.Float32(), float32(0.1))
+	})
+
+	t.Run("Strings", func(t *testing.T) {
+		x := Strings([]string{"a", "b"}).Slice(1, 2)
+		assert(t, "res", x.String(), "[b]")
+		assert(t, "type", x.t, sliceType(TypeString))
+	})
+
+	t.Run("Int32s", func(t *testing.T) {
+		x := NewSlice(TypeNil, []Value{Int(1)})
+		assert(t, "res", fmt.Sprint(x.Int32s()), "[1]")
+	})
+}
+
 func Test_NativeSlice(t *testing.T) {
 	t.Run("data", func(t *testing.T) {
 		r := Wrap(&testSlice{data: []float64{6, 7}})
@@ -357,7 +427,7 @@ func Test_String(t *testing.T) {
 	})
 
 	t.Run("Set_panic", func(t *testing.T) {
-		defer expectPanic(t, "unsupported")
+		defer expectPanic(t, "cannot assign to string index")
 		v := String("*")
 		v.Set(Int(0), Int(0))
 	})
@@ -796,3 +866,99 @@ func TestBool(t *testing.T) {
 		}
 	})
 }
+
+var intCorpus = []int64{0, 1, 2, 3, 7, 42, 100, 127, 128, 255, 256, 32767, 46341, 65535, 123456789,
+	1<<31 - 1, 1 << 31, 1<<32 - 1, -1, -2, -7, -42, -128, -129, -987654321, -1 << 31}
+
+func goIntOp[T int32 | uint32 | int8 | uint8](op string, a, b T) (res T, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			ok = false
+		}
+	}()
+	switch op {
+	case "Add":
+		return a + b, true
+	case "Sub":
+		return a - b, true
+	case "Mul":
+		return a * b, true
+	case "Div":
+		return a / b, true
+	case "Mod":
+		return a % b, true
+	case "BitAnd":
+		return a & b, true
+	case "BitOr":
+		return a | b, true
+	case "BitXor":
+		return a ^ b, true
+	case "BitLsh":
+		return a << uint8(b), true
+	case "BitRsh":
+		return a >> uint8(b), true
+	}
+	panic("unknown op: " + op)
+}
+
+func goatIntOp(op string, a, b Value) (res Value, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			ok = false
+		}
+	}()
+	switch op {
+	case "Add":
+		return a.opAdd(b), true
+	case "Sub":
+		return a.opSub(b), true
+	case "Mul":
+		return a.opMul(b), true
+	case "Div":
+		return a.opDiv(b), true
+	case "Mod":
+		return a.opMod(b), true
+	case "BitAnd":
+		return a.opBitAnd(b), true
+	case "BitOr":
+		return a.opBitOr(b), true
+	case "BitXor":
+		return a.opBitXor(b), true
+	case "BitLsh":
+		return a.opBitLsh(b), true
+	case "BitRsh":
+		return a.opBitRsh(b), true
+	}
+	panic("unknown op: " + op)
+}
+
+func testIntDifferential[T int32 | uint32 | int8 | uint8](t *testing.T, typ Type, mk func(T) Value) {
+	for _, op := range []string{"Add", "Sub", "Mul", "Div", "Mod", "BitAnd", "BitOr", "BitXor", "BitLsh", "BitRsh"} {
+		for _, x := range intCorpus {
+			for _, y := range intCorpus {
+				a, b := T(x), T(y)
+				if op == "BitLsh" || op == "BitRsh" {
+					b = T(uint8(y) % 40)
+				}
+				want, wantOk := goIntOp(op, a, b)
+				got, gotOk := goatIntOp(op, mk(a), mk(b))
+				if gotOk != wantOk {
+					t.Fatalf("%v %v %v: ok got %v want %v", a, op, b, gotOk, wantOk)
+				}
+				if !wantOk {
+					continue
+				}
+				if got.t != typ || got.num != float64(want) {
+					t.Fatalf("%v %v %v: got %v (%v) want %v", a, op, b, got.num, got.t, want)
+				}
+			}
+		}
+	}
+}
+
+func TestIntDifferential(t *testing.T) {
+	t.Run("int32", func(t *testing.T) { testIntDifferential(t, TypeInt32, Int32) })
+	t.Run("uint32", func(t *testing.T) { testIntDifferential(t, TypeUint32, Uint32) })
+	t.Run("int8", func(t *testing.T) { testIntDifferential(t, TypeInt8, Int8) })
+	t.Run("uint8", func(t *testing.T) { testIntDifferential(t, TypeUint8, Uint8) })
+}
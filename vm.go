@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 type frame struct {
@@ -20,9 +22,15 @@ type VM struct {
 	stack   []Value
 	globals *lookup
 	stdout  io.Writer
+	rand    *rand.Rand
+	clock   Clock
+	co      *coroutine
+	order   MapOrder
+	root    *VM // made by New, see funcT.vm
 
 	backtrace []pos
 	frame     frame
+	iters     []*pullT // started range-over-func iterators, see stopIters
 }
 
 func (v *VM) Set(key string, value Value) { v.globals.Set(key, value) }
@@ -32,26 +40,71 @@ type VMOption func(*vmConfig)
 type vmConfig struct {
 	stdout  io.Writer
 	loaders []func(*VM)
+	source  rand.Source
+	clock   Clock
+	order   MapOrder
 }
 
+// MapOrder selects the order range and maps.Keys visit map entries in.
+// MapOrderDefault is unspecified like Go: it is not randomized, but scripts
+// must not depend on it; use MapOrderInsertion when they need to.
+type MapOrder int
+
+const (
+	MapOrderDefault   MapOrder = iota // unspecified, like Go
+	MapOrderInsertion                 // by when each key was inserted
+	MapOrderSorted                    // by key, as fmt prints maps
+)
+
+// Clock backs time.Now, time.Sleep and timers.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time        { return time.Now() }
+func (realClock) Sleep(d time.Duration) { time.Sleep(d) }
+
 func WithStdout(v io.Writer) VMOption     { return func(c *vmConfig) { c.stdout = v } }
 func WithLoaders(v ...func(*VM)) VMOption { return func(c *vmConfig) { c.loaders = v } }
 
+// WithRandSeed seeds the VM's math/rand source.
+func WithRandSeed(v int64) VMOption { return func(c *vmConfig) { c.source = rand.NewSource(v) } }
+
+// WithRandSource sets the VM's math/rand source.
+func WithRandSource(v rand.Source) VMOption { return func(c *vmConfig) { c.source = v } }
+
+// WithClock replaces the wall clock, e.g. for virtual time in tests.
+func WithClock(v Clock) VMOption { return func(c *vmConfig) { c.clock = v } }
+
+// WithMapOrder makes map iteration deterministic, e.g. for replays.
+func WithMapOrder(v MapOrder) VMOption { return func(c *vmConfig) { c.order = v } }
+
 func New(options ...VMOption) *VM {
-	vm := &VM{
-		globals: newGlobals(),
-	}
-	loadBuiltins(vm)
 	config := vmConfig{
 		stdout: os.Stdout,
+		clock:  realClock{},
 	}
 	for _, o := range options {
 		o(&config)
 	}
+	if config.source == nil {
+		config.source = rand.NewSource(time.Now().UnixNano())
+	}
+	vm := &VM{
+		globals: newGlobals(),
+		stdout:  config.stdout,
+		rand:    rand.New(config.source),
+		clock:   config.clock,
+		order:   config.order,
+	}
+	vm.root = vm
+	loadBuiltins(vm)
 	for _, l := range config.loaders {
 		l(vm)
 	}
-	vm.stdout = config.stdout
 	return vm
 }
 
@@ -67,19 +120,70 @@ func (v *VM) btErr(r any) error {
 		}
 		lines = append(lines, fmt.Sprintf("\t%v", pos.String(v.globals)))
 	}
-	return errors.New(strings.Join(lines, "\n"))
+	err, _ := r.(error)
+	return &backtraceError{msg: strings.Join(lines, "\n"), err: err}
 }
 
+type backtraceError struct {
+	msg string
+	err error
+}
+
+func (e *backtraceError) Error() string { return e.msg }
+func (e *backtraceError) Unwrap() error { return e.err }
+
+// Runtime error kinds, match with errors.Is.
+var (
+	ErrIndexOutOfRange = errors.New("index out of range")
+	ErrNilMap          = errors.New("assignment to entry in nil map")
+	ErrNilDereference  = errors.New("invalid memory address or nil pointer dereference")
+	ErrDivideByZero    = errors.New("integer divide by zero")
+	ErrNegativeShift   = errors.New("negative shift amount")
+)
+
+type runtimeError struct {
+	kind error
+	msg  string
+}
+
+func (e *runtimeError) Error() string { return "runtime error: " + e.msg }
+func (e *runtimeError) Unwrap() error { return e.kind }
+
+func panicRuntime(kind error, msg string, args ...any) {
+	panic(&runtimeError{kind: kind, msg: fmt.Sprintf(msg, args...)})
+}
+
+func panicIndex(i, n int) {
+	panicRuntime(ErrIndexOutOfRange, "index out of range [%d] with length %d", i, n)
+}
+
+// panicSlice reports s[i:j] out of range, n is the capacity of s or the
+// length of a string.
+func panicSlice(i, j, n int, str bool) {
+	if j > n && str {
+		panicRuntime(ErrIndexOutOfRange, "slice bounds out of range [:%d] with length %d", j, n)
+	}
+	if j > n {
+		panicRuntime(ErrIndexOutOfRange, "slice bounds out of range [:%d] with capacity %d", j, n)
+	}
+	panicRuntime(ErrIndexOutOfRange, "slice bounds out of range [%d:%d]", i, j)
+}
+
+func panicNil() { panicRuntime(ErrNilDereference, ErrNilDereference.Error()) }
+
 func (v *VM) run(codes []instruction, slots int) (rets []Value, err error) {
 	vm := VM{
 		globals: v.globals,
 		stdout:  v.stdout,
+		order:   v.order,
+		root:    v.root,
 		stack:   make([]Value, slots),
 		frame:   frame{Codes: codes},
 	}
 	defer func() {
 		if r := recover(); r != nil {
 			err = vm.btErr(r)
+			vm.stopIters()
 		}
 	}()
 	vm.exec()
@@ -91,6 +195,9 @@ func (v *VM) Func(fnc Value, xRets int, params ...Value) (rets []Value, err erro
 	vm := VM{
 		globals: v.globals,
 		stdout:  v.stdout,
+		co:      v.co,
+		order:   v.order,
+		root:    v.root,
 		stack:   append(params, fnc),
 		frame: frame{Codes: []instruction{{
 			Code: codeCall,
@@ -101,6 +208,7 @@ func (v *VM) Func(fnc Value, xRets int, params ...Value) (rets []Value, err erro
 	defer func() {
 		if r := recover(); r != nil {
 			err = vm.btErr(r)
+			vm.stopIters()
 		}
 	}()
 	vm.exec()
@@ -111,7 +219,10 @@ func (v *VM) Call(name string, xRets int, params ...Value) (rets []Value, err er
 	return v.Func(v.globals.Get(name), xRets, params...)
 }
 
-func (v *VM) Yield() { v.Call(builtinYield, 0) }
+func (v *VM) Yield() {
+	v.Call(builtinYield, 0)
+	v.globals.Get(builtinTimers).getFunc().Value(v)
+}
 
 type RunOption func(*runConfig)
 
@@ -231,6 +342,11 @@ func mkFunc(args, rets, slots int, tokens []instruction) func(v *VM) {
 	empty := make([]Value, slots-args)
 	codes := tokens[args+rets:]
 	return func(v *VM) {
+		for i := 0; i < args; i++ {
+			if t := Type(tokens[i].A); t.isInterface() {
+				v.implements(v.stack[len(v.stack)-args+i], t)
+			}
+		}
 		v.backtrace = append(v.backtrace, v.frame.Codes[v.frame.N].Pos)
 		prev := v.frame
 		v.frame = frame{
@@ -245,6 +361,9 @@ func mkFunc(args, rets, slots int, tokens []instruction) func(v *VM) {
 		v.exec()
 		v.stack = append(v.stack[:v.frame.BaseN], v.stack[topN:]...)
 		for i := 0; i < rets; i++ {
+			if t := Type(tokens[args+i].A); t.isInterface() {
+				v.implements(v.stack[len(v.stack)-rets+i], t)
+			}
 			v.stack[len(v.stack)-rets+i] = v.stack[len(v.stack)-rets+i].assign(Type(tokens[args+i].A))
 		}
 		v.frame = prev
@@ -2,6 +2,7 @@ package goatlang
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io/fs"
 	"strings"
@@ -34,9 +35,21 @@ func TestVM(t *testing.T) {
 		{"inc", "x := 42; x++; x", `43`},
 		{"intDiv", "1 / 2", `0`},
 		{"convert", "float64(1) / 2", `0.5`},
+		{"int32Overflow", "var a int32 = 2147483647; a++; a", `-2147483648`},
+		{"int32MulWrap", "a, b := 123456789, 987654321; c := a * b; c", `-67153019`},
+		{"int32MinDiv", "a, b := -2147483648, -1; c := a / b; c", `-2147483648`},
+		{"int32TruncDiv", "a, b := -7, 2; c, d := a / b, a % b; c; d", `-3 -1`},
+		{"uint32Underflow", "var a uint32; a--; a", `4294967295`},
+		{"uint32Negate", "var a uint32 = 1; b := -a; b", `4294967295`},
+		{"uint8Mul", "var a byte = 200; b := a * 2; b", `144`},
+		{"shiftLeftType", "var a byte = 1; n := 9; b := a << n; c := a << 7; b; c", `0 128`},
+		{"shiftWide", "a := -1; n := 40; b := a >> n; c := a << n; b; c", `-1 0`},
 		{"var", "var x int; x", `0`},
 		{"sliceRange", "x := []int{2,3,5} ; func f() int { res := 0 ; for k,v := range x { res += v } return res }; i := f(); i", `10`},
 		{"mapRange", `x := map[string]int{"a":2,"b":3} ; func f() (string, int) { rk, rv := "", 0; for k,v := range x { rk += k; rv += v } return rk, rv } ; a,b := f(); a; b`, `ab 5`},
+		{"mapRangeReinsert", `m := map[string]int{"a": 1, "b": 2, "c": 3}; delete(m, "a"); m["a"] = 5; n := 0; for range m { n++ }; n; len(m)`, `3 3`},
+		{"mapRangeReinsertInt", `m := map[int]int{1: 1, 2: 2, 3: 3}; delete(m, 1); m[1] = 5; n := 0; for range m { n++ }; n; len(m)`, `3 3`},
+		{"mapRangeReinsertKey", `m := map[[2]int]int{{1, 1}: 1, {2, 2}: 2}; delete(m, [2]int{1, 1}); m[[2]int{1, 1}] = 5; n := 0; for range m { n++ }; n; len(m)`, `2 2`},
 		{"index", `x := map[int]int{40:2}; y := x[40]; y`, `2`},
 		{"indexOk", `x := map[int]int{40:2}; y, ok := x[40]; y; ok`, `2 true`},
 		{"indexNotOk", `x := map[int]int{}; y, ok := x[40]; y; ok`, `0 false`},
@@ -77,12 +90,18 @@ func TestVM(t *testing.T) {
 		{"not", `!true`, `false`},
 		{"stringEq", `"a"=="b"`, `false`},
 		{"sliceEq", `[]int{40} ==[]int{2}`, `false`},
-		{"convertInt", `int(42.5)`, `42`},
-		{"convertByte", `byte(0x101)`, `1`},
+		{"convertInt", `f := 42.5; int(f)`, `42`},
+		{"convertByte", `n := 0x101; byte(n)`, `1`},
 		{"convertByte2", `x := 256+42; byte(x)`, `42`},
 		{"convertString", `string([]byte{52,50})`, `42`},
 		{"convertSlice", `[]byte("*")[0]`, `42`},
 		{"stringIndex", `"*42"[0]`, `42`},
+		{"stringIndexType", `s := "héllo"; b := s[1]; t := __type(b); b; t`, `195 uint8`},
+		{"stringRangeOffsets", `k := ""; for i, r := range "a世b" { k += string(rune('0' + i)) + string(r) }; k`, `0a1世4b`},
+		{"stringRangeInvalid", `n := 0; for _, r := range "a\xffb" { if r == 0xFFFD { n++ } }; n`, `1`},
+		{"stringSliceBytes", `s := "世界"; t := s[3:]; n := len(s); t; n`, `界 6`},
+		{"runeConvert", `r := []rune("a世"); n := len(r); t := __type(r[1]); s := string(r[1:]); n; t; s`, `2 int32 世`},
+		{"byteConvert", `b := []byte("世"); n := len(b); s := string(b); n; s`, `3 世`},
 		{"stringSlice", `"*42"[1:3]`, `42`},
 		{"neq", `4!=2`, `true`},
 		{"stringRange", `func f() int { res := 0 ; for k,v := range "42" { res += k + int(v) } ; return res } ; x := f(); x`, `103`},
@@ -107,6 +126,7 @@ func TestVM(t *testing.T) {
 		{"numericMapDel", `m := map[int]int{1:42}; delete(m,1); len(m)`, `0`},
 		{"numericMapDel2", `m := map[int]int{1:42,2:43,3:44,4:45}; delete(m,1); delete(m,2);delete(m,3);len(m)`, `1`},
 		{"numericMapString", `import "fmt"; m := map[int]int{1:4}; m`, `map[1:4]`},
+		{"mapStringSorted", `m := map[int]string{3: "c", -1: "a", 2: "b"}; n := map[bool]int{true: 1, false: 0}; m; n`, `map[-1:a 2:b 3:c] map[false:0 true:1]`},
 		{"numericMapSprint", `import "fmt"; m := map[int]int{1:4,2:2}; v := fmt.Sprint(m); v == "map[1:4 2:2]" || v == "map[2:2 1:4]"`, `true`},
 		{"numericMapRange", `func f() int { res:=0; m := map[int]int{1:38,2:1} ; for k,v := range m { res += k+v }; return res } ; v := f(); v`, `42`},
 
@@ -171,7 +191,7 @@ func TestVM(t *testing.T) {
 		{"structMethodsString", `type T struct { X int }; func (t *T) M() { }; t := &T{X:42}; t`, `&{X:42}`},
 		{"lenNil", `var v []int; len(v)`, `0`},
 		{"callSkipReturns", `func f() int { return 42 }; f()`, ``},
-		{"byteMul", `x := byte(42)*byte(42); x`, `228`},
+		{"byteMul", `a := byte(42); x := a*a; x`, `228`},
 		{"intConstAddFloat", `x := float64(2.5); y := 40 + x; y`, `42.5`},
 		{"byteConstAddType", `x := byte(6); y := 7 * x; t := __type(y); t`, `uint8`},
 		{"globalConstAssign", `var x byte; x = 42; t := __type(x); t`, `uint8`},
@@ -195,7 +215,7 @@ func TestVM(t *testing.T) {
 		{"funcNilSliceReturn", `func f() []byte { return nil }; t := __type(f()); t`, `[]uint8`},
 		{"funcNilSliceArgAppend", `func f(x []byte) string { x = append(x,42); return __type(x[0]) }; t := f(nil); t`, `uint8`},
 		{"funcNilSliceReturnAppend", `func f() []byte { return nil }; t := __type(append(f(),42)[0]); t`, `uint8`},
-		{"byteOrFix", `b := byte(42) | 256; b`, `42`},
+		{"byteOrFix", `a := byte(42); b := a | 256; b`, `42`},
 		{"copy", `a := []int{0,0}; b := []int{4,2,3}; copy(a,b); a`, `[4 2]`},
 		{"copyString", `a := []int{0}; copy(a,"*") a`, `[42]`},
 		{"setVar", `var x int = 42; x`, `42`},
@@ -257,6 +277,24 @@ func TestVM(t *testing.T) {
 		{"complementByte", `a := ^byte(42); a`, `213`},
 		{"complementByteNeg", `a := ^int8(-43); a`, `42`},
 		{"localConst", `func f() int { const a = 42; return a }; v = f(); v`, `42`},
+		{"constShift", `const big = 1 << 40; x := big >> 30; x`, `1024`},
+		{"constHuge", `const h = 1 << 100; x := h >> 98; x`, `4`},
+		{"constLocalShift", `func f() int { const a = 1 << 40; return a >> 38 }; v := f(); v`, `4`},
+		{"constFloat", `const a = 1 / 2; const b = 1 / 2.0; const c = 1e400 / 1e390; a; b; c`, `0 0.5 1e+10`},
+		{"constFloatVar", `var f float64 = 1 << 40; f`, `1.099511627776e+12`},
+		{"constRune", `const c = 'a' + 1; s := string(c); s`, `b`},
+		{"constComplement", `const m = ^uint8(1); m`, `254`},
+		{"constBool", `const b = 1 < 2 && "a" < "b"; b`, `true`},
+		{"constShadow", `const a = 2; func f() int { a := 3; return a * a }; v := f(); v`, `9`},
+		{"float32", `var f float32 = 0.1; g := f * 3; g`, `0.3`},
+		{"float32Sum", `var f float32; for i := 0; i < 10; i++ { f += 0.1 }; f`, `1.0000001`},
+		{"float32Equals", `var f float32 = 0.1; a := f == 0.1; b := float64(f) == 0.1; a; b`, `true false`},
+		{"float32Convert", `x := 16777217; f := float32(x); g := float64(f); i := int(f); f; g; i`, `1.6777216e+07 1.6777216e+07 16777216`},
+		{"float32Div", `var f float32 = 1; g := f / 3; g`, `0.33333334`},
+		{"float32Const", `const f float32 = 1.0 / 3; const g = f * 3; f; g`, `0.33333334 1`},
+		{"float32Slice", `s := []float32{0.1, 0.2}; s = append(s, 1.0/3); s[0] += 0.2; s`, `[0.3 0.2 0.33333334]`},
+		{"float32Map", `m := map[float32]string{0.1: "a"}; var f float32 = 0.1; v := m[f]; v`, `a`},
+		{"float32Named", `type F float32; var f F = 2.5; g := f / 2; g`, `1.25`},
 		{"interfaceReloadBug", `type T interface { F() }; type T interface { F() }; v := __type(T); v`, `struct`},
 		{"structStructUnsafeString", `type T struct { X *T }; t:=&T{X:&T{}}; t`, `&{X:&{...}}`},
 		{"structStructSafeString", `type P struct { X, Y, Z int }; type T struct { P *P }; t:=&T{P:&P{}}; t`, `&{P:&{X:0 Y:0 Z:0}}`},
@@ -274,7 +312,7 @@ func TestVM(t *testing.T) {
 		{"variadicType", `func f(a ...int) string { return __type(a) }; v := f(); v`, `[]int32`},
 		{"variadicTypeArg", `func f(a ...int) string { return __type(a) }; v := f(42); v`, `[]int32`},
 		{"localStruct", `func f() int { type T struct { X int }; t := &T{X:42}; return t.X }; v := f(); v`, `42`},
-		{"localType", `func f() any { type T byte; var v T = 42; return v }; v := f(); x := __type(v); x`, `uint8`},
+		{"localType", `func f() any { type T byte; var v T = 42; return v }; v := f(); x := __type(v); x`, `f.T`},
 		{"caseBreak", `v := 0; for i:=0; i<1; i++ { switch i { case 0: break } ; v = 42 ; break }; v`, `42`},
 		{"caseBreakDefault", `v := 42; switch true { case true: break; default: v = 0 } ; v`, `42`},
 		{"passCoverage", `v := 0; switch true { case true: v = 42; default: } v`, `42`},
@@ -298,10 +336,10 @@ func TestVM(t *testing.T) {
 		{"globalMapStructType", `type T struct { } ; var t map[string]T; v := __type(t); v`, `map[string]T`},
 		{"funcStructType", `func f() string { type T struct { }; var t T; return __type(t); } ; v := f(); v`, `f.T`},
 		{"packageFuncStructType", `package ext; func f() string { type T struct { }; var t T; return __type(t); } ; v := f(); v`, `ext.f.T`},
-		{"aliasType", `type T struct{}; type B []T; var x B; v := __type(x); v`, `[]T`},
-		{"localAliasType", `func f() string { type T struct{}; type B []T; var x B; return __type(x); } v := f(); v`, `[]f.T`},
-		{"localTypeZero", `type T string ; func f() any { type T byte; var v T; return v }; v := f(); x := __type(v); x`, `uint8`},
-		{"globalTypeZero", `type T string ; func f() { type T byte; }; f(); var v T; x := __type(v); x`, `string`},
+		{"aliasType", `type T struct{}; type B []T; var x B; v := __type(x); v`, `B`},
+		{"localAliasType", `func f() string { type T struct{}; type B []T; var x B; return __type(x); } v := f(); v`, `f.B`},
+		{"localTypeZero", `type T string ; func f() any { type T byte; var v T; return v }; v := f(); x := __type(v); x`, `f.T`},
+		{"globalTypeZero", `type T string ; func f() { type T byte; }; f(); var v T; x := __type(v); x`, `T`},
 		{"structType", `type T struct{}; t := &T{}; v := __type(t); v`, `T`},
 		{"structToNilType", `type T struct {} x := &T{}; x = nil; v := __type(x); v`, `T`},
 		{"nilStructType", `type T struct {}; var t *T; v := __type(t); v`, `T`},
@@ -311,10 +349,136 @@ func TestVM(t *testing.T) {
 		{"aliasType2x", `type T struct {}; type B T; type C B; var t C; v := __type(t); v`, `T`},
 		{"aliasMethod2x", `type T struct {}; func (t *T) F() int { return 42 } ; type B T; type C B; t := &C{}; v := t.F(); v`, `42`},
 		{"orderFields", `type T struct { Z,Y,X int}; t := &T{}; t`, `&{Z:0 Y:0 X:0}`},
-		{"autoAliasInit", `type T struct{X int}; type A []T; x := A{{X:42},{X:43}}; x`, `[&{X:42} &{X:43}]`},
+		{"autoAliasInit", `type T struct{X int}; type A []T; x := A{{X:42},{X:43}}; x`, `[{X:42} {X:43}]`},
 		{"sliceOfAny", `type T struct{X int}; v := []any{1,"hi",[]int{1,2,3},map[int]int{4:2},&T{X:42}}; v`, `[1 hi [1 2 3] map[4:2] &{X:42}]`},
-		{"aliasAliasAlias", `type A int; type B A; type C struct{}; type D C; type E map[B]D; var e E; t := __type(e); t`, `map[int32]C`},
+		{"aliasAliasAlias", `type A int; type B A; type C struct{}; type D C; type E map[B]D; var e E; t := __type(e); t`, `E`},
 		{"lambdaFunc", `func f() func() int { return func() int { return 42 }} ; v := f(); x := v(); x`, `42`},
+		{"genericZero", `func Zero[T any]() T { var z T; return z }; a, b, c := Zero[int](), Zero[string](), Zero[[]int](); x, y, z := __type(a), __type(b), __type(c); x; y; z`, `int32 string []int32`},
+		{"genericInfer", `func Map[T, U any](s []T, f func(T) U) []U { var res []U; for _, v := range s { res = append(res, f(v)) }; return res }; x := Map([]int{1, 2}, func(v int) float64 { return float64(v) / 2 }); t := __type(x); x; t`, `[0.5 1] []float64`},
+		{"genericInferMap", `func Keys[K comparable, V any](m map[K]V) []K { res := make([]K, 0); for k := range m { res = append(res, k) }; return res }; x := Keys(map[string]bool{"a": true}); t := __type(x); x; t`, `[a] []string`},
+		{"genericVariadic", `func Sum[T int | float64](xs ...T) T { var s T; for _, x := range xs { s += x }; return s }; a, b, c := Sum(1.5, 2), Sum(1, 2), Sum[float64](); t := __type(c); a; b; t`, `3.5 3 float64`},
+		{"genericInferStatic", `func Wrap[T any](v T) []T { return []T{v} }; var a any = 1; b := 2; x, y := Wrap(a), Wrap(b); t, u := __type(x), __type(y); t; u`, `[]any []int32`},
+		{"genericInferUntyped", `func Sum[T int | float64](xs ...T) T { var s T; for _, x := range xs { s += x }; return s }; a := Sum(2, 1.5); t := __type(a); a; t`, `3.5 float64`},
+		{"genericInferParam", `func Wrap[T any](v T) []T { return []T{v} }; func f(a any) []any { return Wrap(a) }; x := f(1); t := __type(x); t`, `[]any`},
+		{"genericInferDynamic", `func Id[T any](v T) T { return v }; func g(v any) any { return v }; x, y, z := Id(g(1)), Id(g("a")), Id(g(2)); t, u, w := __type(x), __type(y), __type(z); t; u; w`, `int32 string int32`},
+		{"genericRecursive", `func F[T any](n int) T { if n == 0 { var z T; return z }; return F[T](n - 1) }; x := F[float64](3); t := __type(x); t`, `float64`},
+		{"genericStruct", `type Stack[T any] struct { items []T }; func (s *Stack[T]) Push(v T) { s.items = append(s.items, v) }; func (s *Stack[T]) Pop() T { v := s.items[len(s.items)-1]; s.items = s.items[:len(s.items)-1]; return v }; s := &Stack[int]{}; s.Push(4); s.Push(2); t := __type(s.items); a := s.Pop(); b := s.Pop(); a; b; t`, `2 4 []int32`},
+		{"genericStructNested", `type Node[T any] struct { Val T; Next *Node[T] }; n := &Node[int]{Val: 1, Next: &Node[int]{Val: 2}}; n.Next.Val`, `2`},
+		{"genericStructInfer", `type Pair[K comparable, V any] struct { Key K; Val V }; func Swap[K, V comparable](p *Pair[K, V]) *Pair[V, K] { return &Pair[V, K]{Key: p.Val, Val: p.Key} }; p := Swap(&Pair[string, int]{Key: "a", Val: 1}); p.Key; p.Val`, `1 a`},
+		{"genericAlias", `type List[T any] []T; x := List[string]{"a"}; t := __type(x); t`, `List[string]`},
+		{"embedded", `type Entity struct { X, Y int }; func (e *Entity) Move(dx int) { e.X += dx }; func (e *Entity) Pos() int { return e.X*10 + e.Y }; type Enemy struct { Entity; HP int }; e := &Enemy{HP: 3}; e.Move(2); e.Y = 4; a, b := e.Pos(), e.Entity.X; a; b; e`, `24 2 &{Entity:{X:2 Y:4} HP:3}`},
+		{"embeddedPointer", `type A struct { N int }; func (a *A) Get() int { return a.N }; type B struct { *A }; type C struct { B; M int }; c := &C{B: &B{A: &A{N: 7}}}; x, y := c.Get(), c.N; x; y`, `7 7`},
+		{"embeddedShadow", `type A struct { N int }; func (a *A) Name() string { return "a" }; type B struct { A; N string }; func (b *B) Name() string { return "b" }; b := &B{N: "x"}; x, y, z := b.Name(), b.A.Name(), b.A.N; x; y; z; b.N`, `b a 0 x`},
+		{"embeddedInterface", `type Namer interface { Name() string }; type A struct {}; func (a *A) Name() string { return "a" }; type B struct { A }; var n Namer = &B{}; x := n.Name(); x`, `a`},
+		{"embeddedParam", `type A struct { N int }; func (a *A) Inc() { a.N++ }; type B struct { A }; type C struct { *B; M int }; func f(c *C) int { c.Inc(); c.N += 10; return c.N }; c := &C{B: &B{}}; x := f(c); x; c.B.A.N`, `11 11`},
+		{"embeddedDepth", `type A struct { N int }; type B struct { A }; type C struct { B; N string }; c := &C{N: "c"}; c.B.N = 2; x, y := c.N, c.B.N; x; y`, `c 2`},
+		{"interfaceArg", `type Namer interface { Name() string }; type A struct{}; func (a *A) Name() string { return "a" }; func f(n Namer) string { return n.Name() }; x := f(&A{}); x`, `a`},
+		{"interfaceEmbedded", `type R interface { Read() int }; type RC interface { R; Close() }; type F struct{}; func (f *F) Read() int { return 1 }; func (f *F) Close() {}; var rc RC = &F{}; x := rc.Read(); x`, `1`},
+		{"interfaceNamed", `type Celsius float64; func (c Celsius) String() string { return "c" }; type Stringer interface { String() string }; var s Stringer = Celsius(1); x := s.String(); x`, `c`},
+		{"interfaceValueMethod", `type I interface { M() int }; type A struct{}; func (a A) M() int { return 3 }; var i I = A{}; x := i.M(); x`, `3`},
+		{"interfaceEmbeddedPointer", `type I interface { M() int }; type A struct{}; func (a *A) M() int { return 3 }; type B struct{ *A }; var i I = B{A: &A{}}; x := i.M(); x`, `3`},
+		{"assertInterface", `type I interface { M() int }; type P struct{}; func (p *P) M() int { return 1 }; var a any = &P{}; i := a.(I); x := i.M(); x`, `1`},
+		{"assertConcrete", `type P struct{ N int }; var a any = &P{N: 2}; p := a.(*P); p.N`, `2`},
+		{"assertOk", `type I interface { M() int }; var a any = 1; _, ok := a.(I); n, ok2 := a.(int); ok; ok2; n`, `false true 1`},
+		{"assertOkPointer", `type I interface { M() }; type A struct{}; func (a *A) M() {}; var x any = A{}; _, ok := x.(I); _, ok2 := x.(*A); _, ok3 := x.(A); ok; ok2; ok3`, `false false true`},
+		{"interfaceConvert", `type Namer interface { Name() string }; type A struct{}; func (a A) Name() string { return "a" }; n := Namer(A{}); x := n.Name(); x`, `a`},
+		{"interfaceObject", `import "errors"; type Stringer interface { Error() string }; func show(s Stringer) string { return s.Error() }; x := show(errors.New("e")); x`, `e`},
+		{"interfaceAny", `func show(a any) any { return a }; x := show(42); x`, `42`},
+		{"interfaceLater", `type I interface { M() string }; type T struct{}; type N int; func f() I { return N(1) }; func g() I { var i I = &T{}; return i }; func (t *T) M() string { return "t" }; func (n N) M() string { return "n" }; x := g().M() + f().M(); x`, `tn`},
+		{"interfacePromoted", `type I interface { M() string }; type A struct{}; func (a *A) M() string { return "a" }; type T struct { A }; x := I(&T{}).M(); x`, `a`},
+		{"interfaceMulti", `type I interface { M() }; type T struct{}; func (t *T) M() {}; func f() (I, error) { return &T{}, nil }; var a, b I = &T{}, nil; x, _ := f(); x == nil`, `false`},
+		{"interfaceNil", `type Namer interface { Name() string }; var n Namer; func f() Namer { return nil }; m := f(); n; m`, `nil nil`},
+		{"rangeInt", `n := 0; for i := range 4 { n += i }; t := 0; for range 3 { t++ }; n; t`, `6 3`},
+		{"rangeIntType", `func f() string { var n uint8 = 2; for i := range n { return __type(i) }; return "" }; const c = 2; func g() string { for i := range c { return __type(i) }; return "" }; x, y := f(), g(); x; y`, `uint8 int32`},
+		{"rangeFunc", `type List struct { items []string }; func (l *List) All(yield func(int, string) bool) { for i, s := range l.items { if !yield(i, s) { return } } }; l := &List{items: []string{"a", "b"}}; s := ""; for i, v := range l.All { s += v + string(rune('0' + i)) }; s`, `a0b1`},
+		{"rangeFuncSeq", `func seq(yield func(int) bool) { yield(40); yield(2) }; n := 0; for v := range seq { n += v }; n`, `42`},
+		{"rangeFuncBreak", `var stopped bool; func seq(yield func(int) bool) { i := 0; for { if !yield(i) { stopped = true; return }; i++ } }; n := 0; for v := range seq { if v == 3 { break }; n += v }; n; stopped`, `3 true`},
+		{"rangeFuncReturn", `var stopped bool; func seq(yield func(int) bool) { if !yield(1) { stopped = true } }; func f() int { for v := range seq { return v * 10 }; return 0 }; x := f(); x; stopped`, `10 true`},
+		{"rangeFuncContinue", `var log string; func seq(yield func(int) bool) { for i := 0; i < 3; i++ { if !yield(i) { log += "stop "; return } } }; n := 0; outer: for _, a := range []int{1, 2} { for v := range seq { if v == 1 { continue outer }; n += a + v }; log += "next " }; n; log`, `3 stop stop `},
+		{"rangeFuncBreakOuter", `var log string; func seq(yield func(int) bool) { for i := 0; i < 100; i++ { if !yield(i) { log += "stop "; return } } }; outer: for { for v := range seq { if v == 2 { break outer } } }; log += "done"; log`, `stop done`},
+		{"rangeFuncContinueSelf", `func seq(yield func(int) bool) { for i := 0; i < 4; i++ { if !yield(i) { return } } }; n := 0; loop: for v := range seq { if v % 2 == 0 { continue loop }; n += v }; n`, `4`},
+		{"rangeFuncGoto", `var log string; func seq(yield func(int) bool) { for i := 0; i < 100; i++ { if !yield(i) { log += "stop "; return } } }; func f() int { n := 0; for v := range seq { if v == 3 { goto out }; n += v }; out: log += "out"; return n }; x := f(); x; log`, `3 stop out`},
+		{"rangeFuncGotoInside", `func seq(yield func(int) bool) { for i := 0; i < 3; i++ { if !yield(i) { return } } }; func f() int { n := 0; for v := range seq { if v == 1 { goto skip }; n += v; skip: n += 10 }; return n }; x := f(); x`, `32`},
+		{"minMax", `a, b := 3, 7; x, y := min(a, b, 5), max(a, b, 5); s := min("b", "a", "c"); f := max(1.5, 2); t := __type(f); x; y; s; f; t`, `3 7 a 2 float64`},
+		{"minMaxNaN", `import "math"; n := math.NaN(); a, b, c, d := min(1, n), max(1, n), min(n, 1, 2), max(2, n, 1); a; b; c; d`, `NaN NaN NaN NaN`},
+		{"clearSlice", `s := []int{1, 2, 3}; clear(s); s; len(s)`, `[0 0 0] 3`},
+		{"clearMap", `m := map[string]int{"a": 1}; clear(m); m["b"] = 2; n := 0; for range m { n++ }; len(m); n`, `1 1`},
+		{"newStruct", `type T struct { V int }; p := new(T); p.V = 42; q := p; q.V`, `42`},
+		{"capMake", `s := make([]int, 1, 10); a := cap(s); s = append(s, 2); b := cap(s); len(s); a; b`, `2 10 10`},
+		{"capNil", `var s []int; x := cap(s); x`, `0`},
+		{"makeMapHint", `m := make(map[string]int, 100); m["a"] = 1; len(m)`, `1`},
+		{"structKeyMap", `type P struct { X, Y int }; m := map[P]int{{X: 1, Y: 2}: 3}; m[P{X: 3, Y: 4}] = 7; p := P{X: 1, Y: 2}; a, b, c := m[p], m[P{X: 3, Y: 4}], m[P{X: 4, Y: 3}]; a; b; c; len(m)`, `3 7 0 2`},
+		{"structKeyMapSet", `type P struct { X int }; m := map[P]string{}; p := P{X: 1}; m[p] = "a"; p.X = 2; m[P{X: 1}] = "b"; len(m); m[P{X: 1}]`, `1 b`},
+		{"structKeyNegZero", `type F struct { V float64 }; z := 0.0; m := map[F]int{}; m[F{V: z}] = 1; m[F{V: -z}] = 2; len(m); m[F{V: 0}]`, `1 2`},
+		{"structKeyLarge", `type P struct { A, B, C, D, E, F, G, H, I int }; m := map[P]int{}; m[P{I: 1}] = 1; m[P{E: 1}] = 2; m[P{I: 1}] += 10; len(m); m[P{I: 1}]; m[P{}]`, `2 11 0`},
+		{"arrayElidedSlice", `s := [][2]int{{1, 2}}; t := s[0]; t[0] = 9; a := s[0] == [2]int{1, 2}; s[0][0]; a`, `1 true`},
+		{"arrayElidedMap", `m := map[string][2]int{"a": {1, 2}}; t := m["a"]; t[0] = 9; a := m["a"] == [2]int{1, 2}; m["a"][0]; a`, `1 true`},
+		{"arrayElidedKey", `m := map[[2]int]string{{1, 2}: "x"}; x := m[[2]int{1, 2}]; x`, `x`},
+		{"arrayElidedNested", `s := [][]map[string][2]int{{{"a": {1, 2}}}}; x := s[0][0]["a"] == [2]int{1, 2}; x`, `true`},
+		{"arrayKeyAny", `m := map[any]int{}; m[[2]int{1, 2}] = 1; m[[3]int{1, 2, 0}] = 2; m[[2]int{1, 2}] += 10; len(m); m[[2]int{1, 2}]`, `2 11`},
+		{"arrayKeyMap", `m := map[[2]int]string{}; m[[2]int{1, 2}] = "a"; k := [2]int{1, 2}; x, ok := m[k]; x; ok`, `a true`},
+		{"boolKeyMap", `m := map[bool]string{true: "yes"}; m[false] = "no"; m[true]; m[false]; len(m)`, `yes no 2`},
+		{"anyKeyMap", `m := map[any]int{}; m[1] = 1; m["1"] = 2; m[1.5] = 3; m[true] = 4; a, b, c, d := m[1], m["1"], m[1.5], m[true]; a; b; c; d; len(m)`, `1 2 3 4 4`},
+		{"anyKeyMapTypes", `m := map[any]int{}; var a int = 1; var b float64 = 1; m[a] = 1; m[b] = 2; x := m[int(1)]; len(m); x`, `2 1`},
+		{"pointerKeyMap", `type T struct{}; a, b := &T{}, &T{}; m := map[*T]int{a: 1, b: 2}; x, y := m[a], m[b]; x; y`, `1 2`},
+		{"structKeyRange", `type P struct { X int }; m := map[P]int{{X: 1}: 10, {X: 2}: 20}; n := 0; for k, v := range m { n += k.X + v }; n`, `33`},
+		{"structKeyMake", `type P struct { X int }; m := make(map[P]bool); m[P{X: 1}] = true; a, b := m[P{X: 1}], m[P{X: 2}]; a; b`, `true false`},
+		{"structKeyDelete", `type P struct { X int }; m := map[P]int{{X: 1}: 10}; delete(m, P{X: 1}); len(m)`, `0`},
+		{"byteSlice", `b := []byte("hi"); b[0] = 'H'; b = append(b, '!'); s := string(b); t := __type(b[0]); s; t`, `Hi! uint8`},
+		{"byteSliceWrap", `b := make([]byte, 2); b[0] = 255; b[0]++; b[1] = 300; b`, `[0 44]`},
+		{"float64SliceShare", `a := []float64{1, 2, 3}; b := a[1:]; b[0] = 2.5; a`, `[1 2.5 3]`},
+		{"stringSliceCopy", `a := []string{"a", "b", "c"}; n := []string{"x", "y"}; copy(a, n); a`, `[x y c]`},
+		{"byteSliceCopyString", `b := make([]byte, 3); copy(b, "abcd"); s := string(b); s`, `abc`},
+		{"intSliceClear", `s := []int{1, 2}; clear(s); s`, `[0 0]`},
+		{"nilByteAppend", `var b []byte; b = append(b[:0], 7); t := __type(b); b; t`, `[7] []uint8`},
+		{"structValue", `type T struct { V int }; x := T{}; y := x; y.V = 42; x.V`, `0`},
+		{"structValueArg", `type T struct { V int }; func f(t T) { t.V = 1 }; x := T{}; f(x); x.V`, `0`},
+		{"structValueReturn", `type T struct { V int }; var g = T{V: 1}; func f() T { return g }; x := f(); x.V = 2; g.V`, `1`},
+		{"structPointerReceiver", `type T struct { V int }; func (t *T) Set() { t.V = 3 }; x := T{}; x.Set(); x.V`, `3`},
+		{"structValueReceiver", `type T struct { V int }; func (t T) Set() { t.V = 3 }; x := &T{}; x.Set(); x.V`, `0`},
+		{"structValueField", `type P struct { X int }; type L struct { A, B P }; l := L{}; l.B = l.A; l.B.X = 5; a, b := l.A.X, l.B.X; a; b`, `0 5`},
+		{"structValuePrint", `type T struct { V int }; x := T{V: 1}; p := &x; p.V = 2; x; p`, `{V:2} &{V:2}`},
+		{"structValueEqual", `type T struct { X, Y int }; a, b := T{X: 1, Y: 2}, T{X: 1, Y: 2}; p, q := &a, &b; x, y := a == b, p == q; x; y`, `true false`},
+		{"derefNewline", "type T struct { V int }\nx := T{V: 1}\np := &x\n*p = T{V: 4}\nx", `{V:4}`},
+		{"derefNewlineCall", "a := [3]int{1, 2, 3}\np := &a\nb := len(a)\n*p = [3]int{7, 7, 7}\na; b", `[7 7 7] 3`},
+		{"derefNewlineIncDec", "a := [2]int{1, 2}\np := &a\na[0]++\n*p = [2]int{5, 6}\na", `[5 6]`},
+		{"mulNewline", "x := 2 *\n3\nx", `6`},
+		{"structDeref", `type T struct { V int }; p := &T{V: 1}; x := *p; x.V = 2; *p = T{V: 3}; a, b := p.V, x.V; a; b`, `3 2`},
+		{"structMakeValues", `type T struct { V int }; s := make([]T, 2); s[0].V = 1; t := s[1]; t.V = 2; a, b := s[0].V, s[1].V; a; b`, `1 0`},
+		{"structRangeValues", `type T struct { V int }; s := []T{{V: 1}}; for _, e := range s { e.V = 9 }; s[0].V`, `1`},
+		{"arrayZero", `var a [3]int; a`, `[0 0 0]`},
+		{"arrayLiteral", `a := [3]int{1, 2}; a`, `[1 2 0]`},
+		{"arrayEllipsis", `a := [...]string{"a", "b"}; n := len(a); n`, `2`},
+		{"arrayConst", `const N = 3; var a [N]int; n := len(a); n`, `3`},
+		{"arrayCopy", `a := [2]int{1, 2}; b := a; b[0] = 9; a; b`, `[1 2] [9 2]`},
+		{"arrayNested", `var g [2][2]int; g[1][0] = 5; h := g; h[1][0] = 6; g; h`, `[[0 0] [5 0]] [[0 0] [6 0]]`},
+		{"arrayNestedLiteral", `g := [2][2]int{{1}, {2, 3}}; g`, `[[1 0] [2 3]]`},
+		{"arrayNamed", `type Grid [2]int; func (g Grid) Sum() int { return g[0] + g[1] }; var g Grid; g[1] = 4; s := g.Sum(); s`, `4`},
+		{"arrayEqual", `a, b := [2]int{1, 2}, [2]int{1, 2}; c := a == b; c`, `true`},
+		{"arrayInStruct", `type T struct { A [2]int }; x := T{}; y := x; y.A[0] = 1; x.A; y.A`, `[0 0] [1 0]`},
+		{"arrayArg", `func f(a [2]int) { a[0] = 1 }; var a [2]int; f(a); a`, `[0 0]`},
+		{"labeledContinue", `n := 0; outer: for i := 0; i < 3; i++ { for j := 0; j < 3; j++ { if j == 1 { continue outer }; n++ } }; n`, `3`},
+		{"labeledBreak", `n := 0; outer: for i := 0; i < 3; i++ { for j := 0; j < 3; j++ { if i == 1 { break outer }; n++ } }; n`, `3`},
+		{"labeledRange", `n := 0; outer: for _, a := range []int{1, 2, 3} { for _, b := range []int{1, 2} { if a*b == 4 { break outer }; n += a * b } }; n`, `5`},
+		{"breakSwitchInFor", `n := 0; for i := 0; i < 5; i++ { switch i { case 2: break }; n++ }; n`, `5`},
+		{"labeledBreakSwitch", `n := 0; loop: for i := 0; i < 5; i++ { switch i { case 2: break loop }; n++ }; n`, `2`},
+		{"goto", `func f() int { i := 0; loop: i++; if i < 5 { goto loop }; return i }; x := f(); x`, `5`},
+		{"namedResults", `func f(s string) (n int, err error) { n = len(s); return }; a, b := f("abc"); a; b`, `3 nil`},
+		{"namedResultsZero", `func f() (x float64, p []int, m map[string]int) { return }; a, b, c := f(); a; b; c`, `0 [] map[]`},
+		{"namedResultsExplicit", `func f() (n int) { n = 1; return 2 }; x := f(); x`, `2`},
+		{"namedResultsType", `func f() (n uint8) { n = 255; n++; return }; x := f(); t := __type(x); x; t`, `0 uint8`},
+		{"namedResultsLambda", `func f() (n int) { g := func() (m int) { m = 3; return }; n = g() + 1; return }; x := f(); x`, `4`},
+		{"namedIntMethod", `type Dir int; func (d Dir) String() string { if d == 0 { return "N" }; return "S" }; var d Dir = 1; x := d.String(); x`, `S`},
+		{"namedIntArith", `type Dir int; func (d Dir) Next() Dir { return (d + 1) % 4 }; d := Dir(3); x := d.Next().Next(); x`, `1`},
+		{"namedSliceMethod", `type Grid []int; func (g Grid) At(i int) int { return g[i] * 10 }; func f() int { g := Grid{1, 2, 3}; return g.At(1) }; x := f(); x`, `20`},
+		{"namedSliceMake", `type Grid []int; func (g Grid) Len() int { return len(g) }; g := make(Grid, 3); x := g.Len(); x`, `3`},
+		{"namedMapMethod", `type Set map[string]bool; func (s Set) Has(k string) bool { return s[k] }; s := Set{}; s["a"] = true; x, y := s.Has("a"), s.Has("b"); x; y`, `true false`},
+		{"namedStringArg", `type Name string; func (n Name) Hi() string { return "hi " + string(n) }; func f(n Name) string { return n.Hi() }; x := f("bob"); x`, `hi bob`},
+		{"namedMethodValue", `type Dir int; func (d Dir) Double() Dir { return d * 2 }; d := Dir(4); f := d.Double; x := f(); x`, `8`},
+		{"namedSliceElem", `type Dir int; func (d Dir) Name() string { return "d" + string(rune('0' + d)) }; ds := []Dir{1, 2}; ds = append(ds, 3); x, y := ds[0].Name(), ds[2].Name(); x; y`, `d1 d3`},
+		{"namedSliceElemRange", `type Dir int; func (d Dir) Name() string { return "d" + string(rune('0' + d)) }; s := ""; for _, d := range make([]Dir, 2) { s += d.Name() }; s`, `d0d0`},
+		{"namedMapElem", `type Dir int; func (d Dir) Name() string { return "d" + string(rune('0' + d)) }; m := map[string]Dir{"a": 1}; m["b"] = 2; x, y := m["a"].Name(), m["b"].Name(); x; y`, `d1 d2`},
+		{"namedStringSliceElem", `type Name string; func (n Name) Hi() string { return "hi " + string(n) }; ns := []Name{"bob"}; x := ns[0].Hi(); x`, `hi bob`},
 
 		// approximate according to Go
 		{"~funcType", `func t() {}; v := __type(t); v`, `func`},
@@ -349,7 +513,6 @@ func TestVM(t *testing.T) {
 		{"!anyToMapToNilType", `var x any; x = map[string]int{}; x = nil; t := __type(x); t`, `map[string]int32`},
 		{"!anyToFuncToNilType", `func f() {} var x any; x = f; x = nil; t := __type(x); t`, `func`},
 		{"!anyToStructToNilType", `type T struct {} ; var x any; x = &T{}; x = nil; t := __type(x); t`, `T`},
-		{"!structNonPointer", `type T struct { V int } ; x := T{}; y = x; y.V = 42; x.V`, `42`},
 	}
 	opts := []struct {
 		suffix   string
@@ -407,11 +570,27 @@ func TestVM_error(t *testing.T) {
 		Err  string
 	}{
 		{"tooManyArgs", `func f() { } ; f(42)`, `incorrect args`},
+		{"structNoField", `type T struct { X, Y int }; func f() *T { return &T{} }; y := f().Nope; y`, `has no field or method Nope`},
+		{"structSetNoField", `type T struct { X, Y int }; func f() *T { return &T{} }; f().Nope = 1`, `has no field Nope`},
 		{"notEnoughArgs", `func f(a int) { } ; f()`, `incorrect args`},
 		{"notEnoughReturns", `func f() {} ; x := f()`, `incorrect returns`},
 		{"backtrace", `package main; func f() { g() } func g() { die() } f()`, `main.g(...)`},
 		{"backtraceBottom", `package main; func f() { g() } func g() { die() } f()`, `main.f(...)`},
 		{"panic", `panic("hello")`, `hello`},
+		{"stringSet", `s := "abc"; s[0] = 42`, `cannot assign to string index`},
+		{"interfaceArg", `type I interface { M() }; type T struct{}; func f(i I) {}; f(&T{})`, `T does not implement I (missing method M)`},
+		{"interfaceInt", `type Namer interface { Name() string }; func show(n Namer) {}; show(42)`, `number does not implement Namer (missing method Name)`},
+		{"interfaceString", `type Namer interface { Name() string }; func show(n Namer) {}; show("str")`, `string does not implement Namer (missing method Name)`},
+		{"interfaceSlice", `type Namer interface { Name() string }; func show(n Namer) {}; show([]int{1})`, `[]int32 does not implement Namer (missing method Name)`},
+		{"interfacePointerReceiver", `type I interface { M() }; type P struct{}; func (p *P) M() {}; var a any = P{}; var i I = a; i`, `P does not implement I (missing method M)`},
+		{"assertMissing", `type I interface { M() }; type P struct{}; var a any = &P{}; i := a.(I); i`, `interface conversion: P is not I: missing method M`},
+		{"assertNil", `var a any; x := a.(int); x`, `interface conversion: interface is nil, not int32`},
+		{"assertType", `var a any = "s"; x := a.(int); x`, `interface conversion: interface is string, not int32`},
+		{"interfaceChecked", `type I interface { M() }; type A struct{}; func (a *A) M() {}; type B struct{}; func f(i I) {}; f(&A{}); f(&A{}); f(&B{})`, `B does not implement I (missing method M)`},
+		{"interfaceObject", `import "errors"; type Namer interface { Name() string }; func show(n Namer) {}; show(errors.New("x"))`, `does not implement Namer (missing method Name)`},
+		{"makeCap", `n := 2; s := make([]int, n, 1)`, `makeslice: len out of range`},
+		{"genericCannotInfer", `func F[T any](v T) T { return v }; func g() any { return nil }; F(g())`, `in call to F, cannot infer T`},
+		{"rangeFuncPanic", `func seq(yield func(int) bool) { yield(1); panic("boom") }; for v := range seq { v }`, `boom`},
 	}
 	for _, row := range tests {
 		t.Run(row.Name, func(t *testing.T) {
@@ -439,6 +618,49 @@ func TestVM_error(t *testing.T) {
 	}
 }
 
+func TestVM_runtimeError(t *testing.T) {
+	tests := []struct {
+		Name string
+		In   string
+		Err  string
+		Kind error
+	}{
+		{"index", `s := []int{1,2,3}; i := 5; s[i]`, `runtime error: index out of range [5] with length 3`, ErrIndexOutOfRange},
+		{"indexSet", `s := []int{1,2,3}; i := -1; s[i] = 42`, `index out of range [-1] with length 3`, ErrIndexOutOfRange},
+		{"indexNil", `var s []int; s[0]`, `index out of range [0] with length 0`, ErrIndexOutOfRange},
+		{"indexString", `s := "abc"; s[3]`, `index out of range [3] with length 3`, ErrIndexOutOfRange},
+		{"sliceBounds", `s := []int{1,2,3}; s[1:5]`, `slice bounds out of range [:5] with capacity 3`, ErrIndexOutOfRange},
+		{"sliceBoundsString", `s := "abc"; j := 5; s[1:j]`, `slice bounds out of range [:5] with length 3`, ErrIndexOutOfRange},
+		{"sliceBoundsInverted", `s := "abc"; i := 2; s[i:1]`, `slice bounds out of range [2:1]`, ErrIndexOutOfRange},
+		{"nilMap", `var m map[string]int; m["x"] = 42`, `assignment to entry in nil map`, ErrNilMap},
+		{"nilStruct", `type T struct { X int }; var p *T; p.X`, `invalid memory address or nil pointer dereference`, ErrNilDereference},
+		{"nilStructSet", `type T struct { X int }; var p *T; p.X = 42`, `invalid memory address or nil pointer dereference`, ErrNilDereference},
+		{"nilFunc", `var f func(); f()`, `invalid memory address or nil pointer dereference`, ErrNilDereference},
+		{"divideByZero", `a, b := 42, 0; a / b`, `integer divide by zero`, ErrDivideByZero},
+		{"modByZero", `var a, b uint32 = 42, 0; a % b`, `integer divide by zero`, ErrDivideByZero},
+	}
+	for _, row := range tests {
+		t.Run(row.Name, func(t *testing.T) {
+			vm := New()
+			_, err := vm.Eval(mapFS{}, "eval", row.In)
+			if err == nil || !strings.Contains(err.Error(), row.Err) {
+				t.Fatalf("Eval error got %v want %v", err, row.Err)
+			}
+			if !errors.Is(err, row.Kind) {
+				t.Fatalf("Eval error %v is not %v", err, row.Kind)
+			}
+		})
+	}
+}
+
+func TestVM_runtimeError_callback(t *testing.T) {
+	vm := New()
+	_, err := vm.Eval(mapFS{}, "eval", `import "golang.org/x/exp/slices"; s := []int{2,1}; slices.SortFunc(s, func(a, b int) bool { return a / 0 < b })`)
+	if !errors.Is(err, ErrDivideByZero) {
+		t.Fatalf("Eval error %v is not %v", err, ErrDivideByZero)
+	}
+}
+
 func TestVM_WithStdout(t *testing.T) {
 	stdout := &bytes.Buffer{}
 	vm := New(WithStdout(stdout))
@@ -456,6 +678,35 @@ func TestVM_WithLoaders(t *testing.T) {
 	assert(t, "loaded", loaded, true)
 }
 
+func TestVM_WithMapOrder(t *testing.T) {
+	const src = `import "golang.org/x/exp/maps"
+		m := map[string]int{"c": 3, "a": 1, "d": 4}
+		m["b"] = 2
+		delete(m, "c")
+		m["c"] = 3
+		s := ""
+		for k, v := range m { s += k; if k == "d" { delete(m, "b") } }
+		print(s, maps.Keys(m), m)`
+	tests := []struct {
+		Name  string
+		Order MapOrder
+		Want  string
+	}{
+		{"insertion", MapOrderInsertion, "adc [a d c] map[a:1 c:3 d:4]"},
+		{"sorted", MapOrderSorted, "abcd [a c d] map[a:1 c:3 d:4]"},
+	}
+	for _, row := range tests {
+		t.Run(row.Name, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			vm := New(WithStdout(stdout), WithMapOrder(row.Order))
+			if _, err := vm.Eval(mapFS{}, "stdin", src); err != nil {
+				t.Fatalf("Eval err got %v want nil", err)
+			}
+			assert(t, "stdout", stdout.String(), row.Want)
+		})
+	}
+}
+
 func TestVM_unknown(t *testing.T) {
 	want := "unknown code"
 	codes := []instruction{{Code: -42}}
@@ -522,6 +773,48 @@ func TestVM_Func_error(t *testing.T) {
 	}
 }
 
+func TestVM_AsError(t *testing.T) {
+	vm := New()
+	vm.Set("main.wrap", NewFunc(1, 1, func(v *VM, args []Value) Value {
+		return Error(fmt.Errorf("host: %w", args[0].AsError()))
+	}))
+	_, err := vm.Eval(mapFS{}, "test", `package main
+import "errors"
+import "os"
+type E struct { Code int }
+func (e *E) Error() string { return "code " + string(rune('0' + e.Code)) }
+func (e *E) Unwrap() error { return os.ErrNotExist }
+func F() error { return &E{Code: 4} }
+func G() bool { var e *E; return errors.As(wrap(F()), &e) && e.Code == 4 }
+type N string
+func (n N) Error() string { return "n " + string(n) }
+func H() error { return N("x") }`)
+	if err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+	rets, err := vm.Call("main.F", 1)
+	if err != nil {
+		t.Fatalf("Call error: %v", err)
+	}
+	res := rets[0].AsError()
+	assert(t, "res", res.Error(), "code 4")
+	assert(t, "errors.Is", errors.Is(res, fs.ErrNotExist), true)
+	assert(t, "Error", Error(fs.ErrExist).AsError(), fs.ErrExist)
+	assert[error](t, "nil", Nil().AsError(), nil)
+	assert[error](t, "notError", Int(42).AsError(), nil)
+	rets, err = vm.Call("main.G", 1)
+	if err != nil {
+		t.Fatalf("Call error: %v", err)
+	}
+	assert(t, "errors.As", rets[0].Bool(), true)
+	rets, err = vm.Call("main.H", 1)
+	if err != nil {
+		t.Fatalf("Call error: %v", err)
+	}
+	assert(t, "named", vm.AsError(rets[0]).Error(), "n x")
+	assert(t, "root", vm.Get("main.F").getFunc().vm, vm)
+}
+
 func TestVM_Eval(t *testing.T) {
 	t.Run("Happy", func(t *testing.T) {
 		vm := New()